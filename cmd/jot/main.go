@@ -1,9 +1,26 @@
 package main
 
 import (
+	"os"
+
+	cmdclient "github.com/kyleterry/jot/pkg/cmd/client"
+	cmdencrypt "github.com/kyleterry/jot/pkg/cmd/encrypt"
 	cmdserver "github.com/kyleterry/jot/pkg/cmd/server"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "encrypt":
+			cmdencrypt.Main()
+
+			return
+		case "push", "get", "edit", "rm", "img":
+			cmdclient.Main()
+
+			return
+		}
+	}
+
 	cmdserver.Main()
 }
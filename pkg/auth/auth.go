@@ -2,6 +2,7 @@ package auth
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/cloudflare/gokey"
@@ -78,6 +79,27 @@ func NewSeedFile(mp MasterPassword, loc SeedFileLocation, spec *gokey.PasswordSp
 	}, nil
 }
 
+// encryptionKeyRealm is the gokey realm the encryption key is derived under.
+// Object keys never contain spaces, so no jot password shares it.
+const encryptionKeyRealm = "jot encryption key"
+
+// EncryptionKey derives a 256 bit key for encrypting stored objects from the
+// master password and seed. The same password and seed always give the same
+// key.
+func (sf *SeedFile) EncryptionKey() ([]byte, error) {
+	r, err := gokey.GetRaw(sf.password, encryptionKeyRealm, sf.content, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+
+	return key, nil
+}
+
 func ProvideAllSeedFiles(sf *SeedFile) []*SeedFile {
 	return []*SeedFile{sf}
 }
@@ -0,0 +1,423 @@
+// Package blob stores content once no matter how many objects hold it. Blobs
+// are named by the SHA-256 sum of their content and count their references,
+// so the filesystem backends of jots and galleries can point at the same blob
+// and it's only deleted when the last object that points at it is.
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/wire"
+	"github.com/kyleterry/jot/pkg/compression"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+var ProviderSet = wire.NewSet(
+	wire.Struct(new(Options), "*"),
+	New,
+)
+
+// refsSuffix is appended to the path of a blob to build the name of the file
+// that counts its references.
+const refsSuffix = ".refs"
+
+type Options struct {
+	StorageDir config.DataDir
+	// Compression is what new blobs are compressed with. Blobs already
+	// written keep the compression they were written with.
+	Compression config.Compression
+}
+
+type Store struct {
+	// mu serializes changes to reference counts.
+	mu          sync.Mutex
+	path        string
+	compression compression.Algorithm
+
+	// sizes holds the size of the content of blobs that were read, which
+	// never changes since blobs are named by their content.
+	sizesMu sync.Mutex
+	sizes   map[string]int64
+}
+
+// Put stores content and adds a reference to it. Content that's already
+// stored isn't written again. It returns the sum the content is stored under.
+func (s *Store) Put(content io.Reader) (string, error) {
+	f, err := os.CreateTemp(s.path, "tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := f.Chmod(config.FilePermissions); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+
+	if err := compression.Encode(f, io.TeeReader(content, hash), s.compression); err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.refs(sum)
+	if err != nil {
+		return "", err
+	}
+
+	if refs == 0 {
+		if err := os.MkdirAll(filepath.Dir(s.blobPath(sum)), config.DirectoryPermissions); err != nil {
+			return "", err
+		}
+
+		if err := os.Rename(f.Name(), s.blobPath(sum)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.writeRefs(sum, refs+1); err != nil {
+		return "", err
+	}
+
+	return sum, nil
+}
+
+// Ref adds a reference to a blob that's already stored.
+func (s *Store) Ref(sum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.refs(sum)
+	if err != nil {
+		return err
+	}
+
+	if refs == 0 {
+		return errors.NewNotFoundError("blob " + sum)
+	}
+
+	return s.writeRefs(sum, refs+1)
+}
+
+// Unref removes a reference to a blob and deletes the blob when it was the
+// last one. Blobs that are open stay readable until they're closed.
+func (s *Store) Unref(sum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.refs(sum)
+	if err != nil {
+		return err
+	}
+
+	if refs > 1 {
+		return s.writeRefs(sum, refs-1)
+	}
+
+	if err := os.Remove(s.blobPath(sum)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(s.blobPath(sum) + refsSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	s.sizesMu.Lock()
+	delete(s.sizes, sum)
+	s.sizesMu.Unlock()
+
+	return nil
+}
+
+// Open returns the content of a blob.
+func (s *Store) Open(sum string) (io.ReadSeekCloser, error) {
+	path, err := s.checkedPath(sum)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NewNotFoundError("blob " + sum).WithCause(err)
+		}
+
+		return nil, err
+	}
+
+	return compression.Open(f)
+}
+
+// OpenAll returns the content of several blobs one after the other. The blobs
+// are all opened straight away, so they stay readable if they're deleted
+// while the content is read, but each is only read once it's reached.
+func (s *Store) OpenAll(sums []string) (io.ReadSeekCloser, error) {
+	if len(sums) == 1 {
+		return s.Open(sums[0])
+	}
+
+	m := &multiReader{store: s, sums: sums}
+
+	for _, sum := range sums {
+		part, err := s.Open(sum)
+		if err != nil {
+			m.Close()
+
+			return nil, err
+		}
+
+		m.parts = append(m.parts, part)
+	}
+
+	return m, nil
+}
+
+// Size returns the size of the content of a blob.
+func (s *Store) Size(sum string) (int64, error) {
+	path, err := s.checkedPath(sum)
+	if err != nil {
+		return 0, err
+	}
+
+	return compression.Size(path)
+}
+
+// Refs returns the number of references to a blob. Blobs that aren't stored
+// have none.
+func (s *Store) Refs(sum string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refs(sum)
+}
+
+func (s *Store) refs(sum string) (int, error) {
+	path, err := s.checkedPath(sum)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := os.ReadFile(path + refsSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(raw)))
+}
+
+// writeRefs replaces the reference count of a blob. The count is written
+// next to it and renamed over the old one so it's never half written.
+func (s *Store) writeRefs(sum string, refs int) error {
+	path := s.blobPath(sum) + refsSuffix
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(refs)+"\n"), config.FilePermissions); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// blobPath returns where a blob is stored. Blobs are spread over directories
+// named by the first two characters of their sum so none gets too big.
+func (s *Store) blobPath(sum string) string {
+	return filepath.Join(s.path, sum[:2], sum)
+}
+
+// checkedPath returns where a blob is stored after checking that sum is a
+// SHA-256 sum, so it can't point outside the store.
+func (s *Store) checkedPath(sum string) (string, error) {
+	if !ValidSum(sum) {
+		return "", errors.NewUnknownError(fmt.Sprintf("invalid blob sum %q", sum))
+	}
+
+	return s.blobPath(sum), nil
+}
+
+// ValidSum reports whether sum is a hex encoded SHA-256 sum.
+func ValidSum(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(sum)
+
+	return err == nil && strings.ToLower(sum) == sum
+}
+
+// multiReader reads blobs one after the other. Seeking only reads the blobs
+// it skips to find out their size, and only the first time it's needed.
+type multiReader struct {
+	store *Store
+	sums  []string
+	parts []io.ReadSeekCloser
+	// current is the part being read, which is at offset in the content.
+	current int
+	offset  int64
+}
+
+func (m *multiReader) Read(p []byte) (int, error) {
+	for m.current < len(m.parts) {
+		n, err := m.parts[m.current].Read(p)
+		m.offset += int64(n)
+
+		if err != io.EOF {
+			return n, err
+		}
+
+		m.current++
+
+		if m.current < len(m.parts) {
+			if _, err := m.parts[m.current].Seek(0, io.SeekStart); err != nil {
+				return n, err
+			}
+		}
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	return 0, io.EOF
+}
+
+func (m *multiReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		size, err := m.size(len(m.parts))
+		if err != nil {
+			return 0, err
+		}
+
+		offset += size
+	default:
+		return 0, errors.NewUnknownError(fmt.Sprintf("invalid whence %d", whence))
+	}
+
+	if offset < 0 {
+		return 0, errors.NewUnknownError("seek to a negative position")
+	}
+
+	var start int64
+
+	for i := range m.parts {
+		size, err := m.partSize(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if offset < start+size {
+			if _, err := m.parts[i].Seek(offset-start, io.SeekStart); err != nil {
+				return 0, err
+			}
+
+			m.current, m.offset = i, offset
+
+			return offset, nil
+		}
+
+		start += size
+	}
+
+	// positions at or past the end read nothing.
+	m.current, m.offset = len(m.parts), offset
+
+	return offset, nil
+}
+
+func (m *multiReader) Close() error {
+	var err error
+
+	for _, part := range m.parts {
+		if cerr := part.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// size returns the size of the first n parts.
+func (m *multiReader) size(n int) (int64, error) {
+	var size int64
+
+	for i := range n {
+		partSize, err := m.partSize(i)
+		if err != nil {
+			return 0, err
+		}
+
+		size += partSize
+	}
+
+	return size, nil
+}
+
+// partSize returns the size of part i. Finding it out moves the part, so it
+// has to be sought to where it's read from afterwards.
+func (m *multiReader) partSize(i int) (int64, error) {
+	sum := m.sums[i]
+
+	m.store.sizesMu.Lock()
+	size, ok := m.store.sizes[sum]
+	m.store.sizesMu.Unlock()
+
+	if ok {
+		return size, nil
+	}
+
+	size, err := m.parts[i].Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	m.store.sizesMu.Lock()
+	m.store.sizes[sum] = size
+	m.store.sizesMu.Unlock()
+
+	return size, nil
+}
+
+func New(opts *Options) (*Store, error) {
+	path := filepath.Join(string(opts.StorageDir), config.BlobDirectoryName)
+	if err := os.MkdirAll(path, config.DirectoryPermissions); err != nil {
+		return nil, err
+	}
+
+	algorithm, err := compression.Parse(string(opts.Compression))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		path:        path,
+		compression: algorithm,
+		sizes:       make(map[string]int64),
+	}, nil
+}
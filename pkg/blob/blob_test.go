@@ -0,0 +1,110 @@
+package blob_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s, err := blob.New(&blob.Options{StorageDir: config.DataDir(t.TempDir())})
+	require.NoError(t, err)
+
+	sum, err := s.Put(strings.NewReader("content"))
+	require.NoError(t, err)
+	require.True(t, blob.ValidSum(sum))
+
+	again, err := s.Put(strings.NewReader("content"))
+	require.NoError(t, err)
+	require.Equal(t, sum, again)
+
+	require.NoError(t, s.Ref(sum))
+
+	refs, err := s.Refs(sum)
+	require.NoError(t, err)
+	require.Equal(t, 3, refs)
+
+	size, err := s.Size(sum)
+	require.NoError(t, err)
+	require.EqualValues(t, len("content"), size)
+
+	require.NoError(t, s.Unref(sum))
+	require.NoError(t, s.Unref(sum))
+
+	content, err := s.Open(sum)
+	require.NoError(t, err)
+
+	// the last reference goes while the blob is open
+	require.NoError(t, s.Unref(sum))
+
+	raw, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(raw))
+	require.NoError(t, content.Close())
+
+	_, err = s.Open(sum)
+	require.Error(t, err)
+	require.Error(t, s.Ref(sum))
+
+	refs, err = s.Refs(sum)
+	require.NoError(t, err)
+	require.Zero(t, refs)
+
+	_, err = s.Open("../../etc/passwd")
+	require.Error(t, err)
+}
+
+func TestStoreOpenAll(t *testing.T) {
+	s, err := blob.New(&blob.Options{StorageDir: config.DataDir(t.TempDir()), Compression: "zstd"})
+	require.NoError(t, err)
+
+	first, err := s.Put(strings.NewReader(strings.Repeat("first ", 100)))
+	require.NoError(t, err)
+
+	second, err := s.Put(strings.NewReader("second"))
+	require.NoError(t, err)
+
+	content, err := s.OpenAll([]string{first})
+	require.NoError(t, err)
+
+	_, ok := content.(types.EncodedContent)
+	require.True(t, ok)
+	require.NoError(t, content.Close())
+
+	content, err = s.OpenAll([]string{first, second})
+	require.NoError(t, err)
+	defer content.Close()
+
+	raw, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("first ", 100)+"second", string(raw))
+
+	// blobs are read as they're reached, and seeking goes straight to them.
+	size, err := content.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.EqualValues(t, 606, size)
+
+	for _, offset := range []int64{598, 600, 603, 606, 0} {
+		_, err = content.Seek(offset, io.SeekStart)
+		require.NoError(t, err)
+
+		raw, err = io.ReadAll(content)
+		require.NoError(t, err)
+		require.Equal(t, (strings.Repeat("first ", 100) + "second")[offset:], string(raw))
+	}
+
+	// the content stays readable once its blobs are deleted.
+	require.NoError(t, s.Unref(second))
+
+	_, err = content.Seek(600, io.SeekStart)
+	require.NoError(t, err)
+
+	raw, err = io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(raw))
+}
@@ -0,0 +1,400 @@
+// Package client talks to a jot server over its versioned API, /api/v1.
+//
+// Errors the server answers with are returned as *Error, whose Type is the
+// errors.ErrorType the server failed with, so callers can check for them with
+// errors.Is:
+//
+//	if errors.Is(err, client.ErrETagMismatch) {
+//		// someone else edited the jot
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiPath is where the version of the API the client speaks is served.
+const apiPath = "/api/v1"
+
+// Client makes requests to a jot server.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the client send its requests with hc instead of
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// CreateOptions are the optional settings of a new jot or gallery.
+type CreateOptions struct {
+	// Key creates the jot or gallery under a key of your own instead of a
+	// generated one.
+	Key string
+	// TTL deletes the jot or gallery once it passes.
+	TTL time.Duration
+	// The options below only apply to text jots.
+	BurnAfterReading bool
+	ContentType      string
+	Filename         string
+	// Encrypted marks content the caller encrypted itself, see pkg/e2e.
+	Encrypted bool
+}
+
+// Created is a new jot or gallery. Password is needed to change or delete it.
+type Created struct {
+	Key      string `json:"key"`
+	URL      string `json:"url"`
+	Password string `json:"password"`
+}
+
+// Text is a text jot. Content is only set by GetText.
+type Text struct {
+	Key         string    `json:"key"`
+	URL         string    `json:"url"`
+	ETag        string    `json:"etag"`
+	Modified    time.Time `json:"modified"`
+	Expires     time.Time `json:"expires"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Filename    string    `json:"filename"`
+	Encrypted   bool      `json:"encrypted"`
+	ForkOf      string    `json:"fork_of"`
+	Content     []byte    `json:"-"`
+}
+
+// Gallery is an image gallery.
+type Gallery struct {
+	Key      string    `json:"key"`
+	URL      string    `json:"url"`
+	ETag     string    `json:"etag"`
+	Modified time.Time `json:"modified"`
+	Expires  time.Time `json:"expires"`
+	Images   []Image   `json:"images"`
+}
+
+// Image is an image in a gallery.
+type Image struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+}
+
+// ImageFile is an image to upload.
+type ImageFile struct {
+	Name    string
+	Content io.Reader
+}
+
+// base64Encoding is the content_encoding of jot content sent as base64.
+// The client sends all content that way, since JSON strings can only carry
+// UTF-8.
+const base64Encoding = "base64"
+
+// textRequest is the body of requests that write a text jot.
+type textRequest struct {
+	Content         string `json:"content"`
+	ContentEncoding string `json:"content_encoding"`
+	ContentType     string `json:"content_type,omitempty"`
+	Filename        string `json:"filename,omitempty"`
+	TTL             string `json:"ttl,omitempty"`
+	Burn            bool   `json:"burn,omitempty"`
+	Encrypted       bool   `json:"encrypted,omitempty"`
+}
+
+// newTextRequest returns a textRequest that carries content as base64.
+func newTextRequest(content []byte) textRequest {
+	return textRequest{
+		Content:         base64.StdEncoding.EncodeToString(content),
+		ContentEncoding: base64Encoding,
+	}
+}
+
+// textResponse is a text jot as the server describes it, with its content
+// encoded as ContentEncoding says.
+type textResponse struct {
+	Text
+	Content         string `json:"content"`
+	ContentEncoding string `json:"content_encoding"`
+}
+
+// text returns the jot with its content decoded.
+func (resp textResponse) text() (*Text, error) {
+	text := resp.Text
+
+	switch resp.ContentEncoding {
+	case "":
+		text.Content = []byte(resp.Content)
+	case base64Encoding:
+		content, err := base64.StdEncoding.DecodeString(resp.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content: %w", err)
+		}
+
+		text.Content = content
+	default:
+		return nil, fmt.Errorf("unknown content encoding: %s", resp.ContentEncoding)
+	}
+
+	return &text, nil
+}
+
+// CreateText creates a text jot holding content. opts can be nil.
+func (c *Client) CreateText(ctx context.Context, content []byte, opts *CreateOptions) (*Created, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+
+	body := newTextRequest(content)
+	body.ContentType = opts.ContentType
+	body.Filename = opts.Filename
+	body.Burn = opts.BurnAfterReading
+	body.Encrypted = opts.Encrypted
+
+	if opts.TTL > 0 {
+		body.TTL = opts.TTL.String()
+	}
+
+	req, err := c.newJSONRequest(ctx, http.MethodPost, objectPath("txt", opts.Key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Created
+	if err := c.do(req, http.StatusCreated, &created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetText returns a text jot and its content. Getting a jot that burns after
+// reading deletes it.
+func (c *Client) GetText(ctx context.Context, key string) (*Text, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, objectPath("txt", key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp textResponse
+	if err := c.do(req, http.StatusOK, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.text()
+}
+
+// UpdateText replaces the content of a text jot. With an ifMatch ETag, the
+// update only happens if the jot is still at that ETag and fails with
+// ErrETagMismatch otherwise; an empty ifMatch updates the jot whatever it
+// holds. It returns the jot as it is after the update, without its content.
+func (c *Client) UpdateText(ctx context.Context, key, password string, content []byte, ifMatch string) (*Text, error) {
+	req, err := c.newJSONRequest(ctx, http.MethodPut, objectPath("txt", key), newTextRequest(content))
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth("", password)
+
+	if ifMatch != "" {
+		req.Header.Set("if-match", ifMatch)
+	}
+
+	var text Text
+	if err := c.do(req, http.StatusOK, &text); err != nil {
+		return nil, err
+	}
+
+	return &text, nil
+}
+
+// DeleteText deletes a text jot.
+func (c *Client) DeleteText(ctx context.Context, key, password string) error {
+	return c.delete(ctx, objectPath("txt", key), password)
+}
+
+// UploadImages creates a gallery of images. opts can be nil.
+func (c *Client) UploadImages(ctx context.Context, images []ImageFile, opts *CreateOptions) (*Created, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+
+	for _, img := range images {
+		fw, err := mw.CreateFormFile("images", img.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := io.Copy(fw, img.Content); err != nil {
+			return nil, fmt.Errorf("failed to read image %s: %w", img.Name, err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	path := objectPath("img", opts.Key)
+	if opts.TTL > 0 {
+		path += "?" + url.Values{"ttl": {opts.TTL.String()}}.Encode()
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, path, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("content-type", mw.FormDataContentType())
+
+	var created Created
+	if err := c.do(req, http.StatusCreated, &created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetGallery returns a gallery and lists its images.
+func (c *Client) GetGallery(ctx context.Context, key string) (*Gallery, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, objectPath("img", key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var gallery Gallery
+	if err := c.do(req, http.StatusOK, &gallery); err != nil {
+		return nil, err
+	}
+
+	return &gallery, nil
+}
+
+// DeleteGallery deletes a gallery and its images.
+func (c *Client) DeleteGallery(ctx context.Context, key, password string) error {
+	return c.delete(ctx, objectPath("img", key), password)
+}
+
+func (c *Client) delete(ctx context.Context, path, password string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth("", password)
+
+	return c.do(req, http.StatusOK, nil)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.ResolveReference(ref).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("accept", "application/json")
+
+	return req, nil
+}
+
+func (c *Client) newJSONRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, method, path, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	return req, nil
+}
+
+// do sends req and decodes the response into v, unless v is nil. Responses
+// with a status other than want are returned as *Error.
+func (c *Client) do(req *http.Request, want int, v any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		return newError(resp)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// objectPath returns the API path of the collection route, or of the object
+// under key in it if key isn't empty.
+func objectPath(route, key string) string {
+	if key == "" {
+		return route
+	}
+
+	return route + "/" + url.PathEscape(key)
+}
+
+// New returns a client for the jot server at baseURL, such as
+// https://jot.example.com.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url: %s", baseURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + apiPath + "/"
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
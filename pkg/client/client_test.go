@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/client"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/testutil/testserver"
+	"github.com/stretchr/testify/require"
+)
+
+// withClient runs fn with a client of a server that stores jots and images
+// in a temporary directory.
+func withClient(t *testing.T, fn func(*client.Client)) {
+	ts := testserver.New(t)
+
+	c, err := client.New(ts.URL, client.WithHTTPClient(ts.Client()))
+	require.NoError(t, err)
+
+	fn(c)
+}
+
+func minimalPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	return buf.Bytes()
+}
+
+func TestClientText(t *testing.T) {
+	withClient(t, func(c *client.Client) {
+		ctx := context.Background()
+
+		created, err := c.CreateText(ctx, []byte("hello\n"), &client.CreateOptions{TTL: time.Hour})
+		require.NoError(t, err)
+		require.NotEmpty(t, created.Key)
+		require.NotEmpty(t, created.Password)
+
+		text, err := c.GetText(ctx, created.Key)
+		require.NoError(t, err)
+		require.Equal(t, created.URL, text.URL)
+		require.Equal(t, []byte("hello\n"), text.Content)
+		require.EqualValues(t, 6, text.Size)
+		require.False(t, text.Expires.IsZero())
+
+		updated, err := c.UpdateText(ctx, created.Key, created.Password, []byte("hello world\n"), text.ETag)
+		require.NoError(t, err)
+		require.NotEqual(t, text.ETag, updated.ETag)
+		require.EqualValues(t, 12, updated.Size)
+
+		t.Run("stale etag", func(t *testing.T) {
+			_, err := c.UpdateText(ctx, created.Key, created.Password, []byte("again\n"), text.ETag)
+			require.ErrorIs(t, err, client.ErrETagMismatch)
+
+			var clientErr *client.Error
+			require.True(t, stderrors.As(err, &clientErr))
+			require.Equal(t, errors.ErrorTypeETagMismatch, clientErr.Type)
+			require.Equal(t, http.StatusPreconditionFailed, clientErr.StatusCode)
+		})
+
+		t.Run("wrong password", func(t *testing.T) {
+			_, err := c.UpdateText(ctx, created.Key, "wrong", []byte("again\n"), "")
+			require.ErrorIs(t, err, client.ErrInvalidPassword)
+
+			require.ErrorIs(t, c.DeleteText(ctx, created.Key, "wrong"), client.ErrInvalidPassword)
+		})
+
+		t.Run("taken key", func(t *testing.T) {
+			_, err := c.CreateText(ctx, []byte("mine\n"), &client.CreateOptions{Key: "my-notes"})
+			require.NoError(t, err)
+
+			_, err = c.CreateText(ctx, []byte("mine too\n"), &client.CreateOptions{Key: "my-notes"})
+			require.ErrorIs(t, err, client.ErrConflict)
+		})
+
+		t.Run("binary", func(t *testing.T) {
+			content := []byte{0xff, 0xfe, 0x00, 'j', 'o', 't', 0x80, '\n'}
+
+			created, err := c.CreateText(ctx, content, nil)
+			require.NoError(t, err)
+
+			text, err := c.GetText(ctx, created.Key)
+			require.NoError(t, err)
+			require.Equal(t, content, text.Content)
+
+			content = append(content, 0xc3, 0x28)
+
+			_, err = c.UpdateText(ctx, created.Key, created.Password, content, text.ETag)
+			require.NoError(t, err)
+
+			text, err = c.GetText(ctx, created.Key)
+			require.NoError(t, err)
+			require.Equal(t, content, text.Content)
+		})
+
+		t.Run("burn after reading", func(t *testing.T) {
+			created, err := c.CreateText(ctx, []byte("secret"), &client.CreateOptions{BurnAfterReading: true})
+			require.NoError(t, err)
+
+			text, err := c.GetText(ctx, created.Key)
+			require.NoError(t, err)
+			require.Equal(t, []byte("secret"), text.Content)
+
+			_, err = c.GetText(ctx, created.Key)
+			require.ErrorIs(t, err, client.ErrNotFound)
+		})
+
+		require.NoError(t, c.DeleteText(ctx, created.Key, created.Password))
+
+		_, err = c.GetText(ctx, created.Key)
+		require.ErrorIs(t, err, client.ErrNotFound)
+		require.NotErrorIs(t, err, client.ErrExpired)
+	})
+}
+
+func TestClientImages(t *testing.T) {
+	withClient(t, func(c *client.Client) {
+		ctx := context.Background()
+
+		created, err := c.UploadImages(ctx, []client.ImageFile{
+			{Name: "pixel.png", Content: bytes.NewReader(minimalPNG(t))},
+		}, nil)
+		require.NoError(t, err)
+
+		gallery, err := c.GetGallery(ctx, created.Key)
+		require.NoError(t, err)
+		require.Equal(t, created.URL, gallery.URL)
+		require.Equal(t, []client.Image{{
+			Name:        "pixel.png",
+			URL:         created.URL + "/pixel.png",
+			ContentType: "image/png",
+		}}, gallery.Images)
+
+		_, err = c.UploadImages(ctx, nil, nil)
+		require.ErrorIs(t, err, client.ErrBadRequest)
+
+		require.ErrorIs(t, c.DeleteGallery(ctx, created.Key, "wrong"), client.ErrInvalidPassword)
+		require.NoError(t, c.DeleteGallery(ctx, created.Key, created.Password))
+
+		_, err = c.GetGallery(ctx, created.Key)
+		require.ErrorIs(t, err, client.ErrNotFound)
+	})
+}
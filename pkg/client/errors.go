@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+// maxErrorBody is how much of an error response is read for its message.
+const maxErrorBody = 64 << 10
+
+// Error is an error the server answered a request with. Type is the kind of
+// error the server failed with.
+type Error struct {
+	Type       errors.ErrorType
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jot: %s (%d %s)", e.Message, e.StatusCode, e.Type)
+}
+
+// Is reports whether target is an *Error of the same Type, so errors can be
+// checked against ErrNotFound and the others below with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.Type == e.Type
+}
+
+// Errors to check the errors of a Client against with errors.Is.
+var (
+	ErrInvalidPassword   = &Error{Type: errors.ErrorTypeInvalidPassword}
+	ErrNotFound          = &Error{Type: errors.ErrorTypeNotFound}
+	ErrUnknown           = &Error{Type: errors.ErrorTypeUnknown}
+	ErrETagMismatch      = &Error{Type: errors.ErrorTypeETagMismatch}
+	ErrInvalidKey        = &Error{Type: errors.ErrorTypeInvalidKey}
+	ErrExpired           = &Error{Type: errors.ErrorTypeExpired}
+	ErrBadRequest        = &Error{Type: errors.ErrorTypeBadRequest}
+	ErrTooLarge          = &Error{Type: errors.ErrorTypeTooLarge}
+	ErrConflict          = &Error{Type: errors.ErrorTypeConflict}
+	ErrUnsupportedFormat = &Error{Type: errors.ErrorTypeUnsupportedFormat}
+	ErrMethodNotAllowed  = &Error{Type: errors.ErrorTypeMethodNotAllowed}
+)
+
+// statusErrorTypes are the kinds of errors responses without a problem body
+// are taken to be, going by their status.
+var statusErrorTypes = map[int]errors.ErrorType{
+	http.StatusBadRequest:            errors.ErrorTypeBadRequest,
+	http.StatusUnauthorized:          errors.ErrorTypeInvalidPassword,
+	http.StatusNotFound:              errors.ErrorTypeNotFound,
+	http.StatusMethodNotAllowed:      errors.ErrorTypeMethodNotAllowed,
+	http.StatusConflict:              errors.ErrorTypeConflict,
+	http.StatusGone:                  errors.ErrorTypeExpired,
+	http.StatusPreconditionFailed:    errors.ErrorTypeETagMismatch,
+	http.StatusRequestEntityTooLarge: errors.ErrorTypeTooLarge,
+	http.StatusUnsupportedMediaType:  errors.ErrorTypeUnsupportedFormat,
+}
+
+// problem is the body of error responses.
+type problem struct {
+	Detail string `json:"detail"`
+	Code   string `json:"code"`
+}
+
+// newError reads the error a response holds. Problems carry the kind of error
+// in their code, and the status is used for anything else.
+func newError(resp *http.Response) *Error {
+	e := &Error{
+		Type:       errors.ErrorTypeUnknown,
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+	}
+
+	if t, ok := statusErrorTypes[resp.StatusCode]; ok {
+		e.Type = t
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return e
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("content-type"))
+	if mediaType == "application/problem+json" {
+		var p problem
+		if err := json.Unmarshal(raw, &p); err == nil {
+			e.Type = errors.ErrorTypeFromString(p.Code)
+			if p.Detail != "" {
+				e.Message = p.Detail
+			}
+
+			return e
+		}
+	}
+
+	if msg := strings.TrimSpace(string(raw)); msg != "" {
+		e.Message = msg
+	}
+
+	return e
+}
@@ -0,0 +1,561 @@
+// Package client is the command line client of jot: the push, get, edit, rm
+// and img commands. The passwords of the jots and galleries it creates are
+// kept in a vault, see pkg/vault, so changing or deleting them later only
+// needs their URL.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	jotclient "github.com/kyleterry/jot/pkg/client"
+	"github.com/kyleterry/jot/pkg/diff"
+	"github.com/kyleterry/jot/pkg/vault"
+)
+
+const (
+	// defaultServer is where new jots are created when JOT_SERVER isn't set.
+	// It matches the default bind address of the server.
+	defaultServer = "http://localhost:8095"
+	// defaultEditor is used to edit jots when neither VISUAL nor EDITOR is set.
+	defaultEditor = "vi"
+)
+
+const usage = `usage:
+  jot push [-server url] [-key key] [-ttl duration] [-burn] [-type content-type] [file]
+  jot get <url>
+  jot edit [-password password] <url>
+  jot rm [-password password] <url>
+  jot img [-server url] [-key key] [-ttl duration] <files...>
+`
+
+// cli runs the commands. Its fields are what the commands read and write
+// outside of the server, so tests can swap them out.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	// server is the base URL new jots and galleries are created on.
+	server     string
+	vault      *vault.Vault
+	httpClient *http.Client
+	// edit lets the user edit the file at path and returns once they're done.
+	edit func(ctx context.Context, path string) error
+}
+
+// object is a jot or gallery on a server, parsed from its URL.
+type object struct {
+	base  string
+	route string
+	key   string
+}
+
+// url returns the canonical URL of the object, which the vault keeps its
+// password by.
+func (o *object) url() string {
+	return o.base + "/" + o.route + "/" + url.PathEscape(o.key)
+}
+
+// parseObject parses the URL of a jot or gallery, such as
+// https://jot.example.com/txt/abc. URLs of a jot's sub-resources, such as its
+// raw content or revisions, and of images in a gallery are accepted too.
+func parseObject(raw string) (*object, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("not a jot url: %s", raw)
+	}
+
+	parts := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(parts) < 2 || (parts[0] != "txt" && parts[0] != "img") || parts[1] == "" {
+		return nil, fmt.Errorf("not a jot url: %s", raw)
+	}
+
+	key, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("not a jot url: %s", raw)
+	}
+
+	return &object{
+		base:  u.Scheme + "://" + u.Host,
+		route: parts[0],
+		key:   key,
+	}, nil
+}
+
+func (c *cli) client(base string) (*jotclient.Client, error) {
+	return jotclient.New(base, jotclient.WithHTTPClient(c.httpClient))
+}
+
+// remember keeps the password of something just created in the vault.
+func (c *cli) remember(created *jotclient.Created) error {
+	o, err := parseObject(created.URL)
+	if err != nil {
+		return err
+	}
+
+	return c.vault.Put(o.url(), created.Password)
+}
+
+// password returns the password given on the command line, or the one the
+// vault kept for o.
+func (c *cli) password(o *object, given string) (string, error) {
+	if given != "" {
+		return given, nil
+	}
+
+	password, ok := c.vault.Get(o.url())
+	if !ok {
+		return "", fmt.Errorf("no password for %s in the vault, pass it with -password", o.url())
+	}
+
+	return password, nil
+}
+
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(c.stderr, usage)
+	}
+
+	return fs
+}
+
+// run runs the command named by args[0] with the rest of args.
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, usage)
+
+		return errors.New("missing command")
+	}
+
+	var err error
+
+	switch args[0] {
+	case "push":
+		err = c.push(ctx, args[1:])
+	case "get":
+		err = c.get(ctx, args[1:])
+	case "edit":
+		err = c.editText(ctx, args[1:])
+	case "rm":
+		err = c.rm(ctx, args[1:])
+	case "img":
+		err = c.img(ctx, args[1:])
+	default:
+		fmt.Fprint(c.stderr, usage)
+
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	// -h already printed the usage, which is all it asked for.
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	return err
+}
+
+// push creates a text jot from a file, or from stdin if no file or - is
+// given, and prints its URL.
+func (c *cli) push(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("push")
+	server := fs.String("server", c.server, "url of the jot server")
+	key := fs.String("key", "", "create the jot under this key")
+	ttl := fs.Duration("ttl", 0, "delete the jot after this long")
+	burn := fs.Bool("burn", false, "delete the jot once it's read")
+	contentType := fs.String("type", "", "content type of the jot")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+
+		return errors.New("push takes at most one file")
+	}
+
+	opts := &jotclient.CreateOptions{
+		Key:              *key,
+		TTL:              *ttl,
+		BurnAfterReading: *burn,
+		ContentType:      *contentType,
+	}
+
+	in := c.stdin
+
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		in = f
+		opts.Filename = filepath.Base(name)
+	}
+
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	jc, err := c.client(*server)
+	if err != nil {
+		return err
+	}
+
+	created, err := jc.CreateText(ctx, content, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := c.remember(created); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout, created.URL)
+
+	return nil
+}
+
+// get prints the content of a jot, or the URLs of the images in a gallery.
+func (c *cli) get(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("get")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+
+		return errors.New("get takes one url")
+	}
+
+	o, err := parseObject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	jc, err := c.client(o.base)
+	if err != nil {
+		return err
+	}
+
+	if o.route == "img" {
+		gallery, err := jc.GetGallery(ctx, o.key)
+		if err != nil {
+			return err
+		}
+
+		for _, img := range gallery.Images {
+			fmt.Fprintln(c.stdout, img.URL)
+		}
+
+		return nil
+	}
+
+	text, err := jc.GetText(ctx, o.key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.stdout.Write(text.Content)
+
+	return err
+}
+
+// editText opens a jot in the user's editor and saves it once they're done.
+// The jot is only replaced if nobody changed it in the meantime. If somebody
+// did, their changes and the user's are merged, and the editor is opened
+// again when they conflict.
+func (c *cli) editText(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("edit")
+	given := fs.String("password", "", "password of the jot, instead of the one in the vault")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+
+		return errors.New("edit takes one url")
+	}
+
+	o, err := parseObject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if o.route != "txt" {
+		return fmt.Errorf("only text jots can be edited: %s", o.url())
+	}
+
+	password, err := c.password(o, *given)
+	if err != nil {
+		return err
+	}
+
+	jc, err := c.client(o.base)
+	if err != nil {
+		return err
+	}
+
+	current, err := jc.GetText(ctx, o.key)
+	if err != nil {
+		return err
+	}
+
+	if current.Encrypted {
+		return fmt.Errorf("%s is end-to-end encrypted, edit it in the browser", o.url())
+	}
+
+	// the file keeps the jot's extension so editors can highlight it.
+	f, err := os.CreateTemp("", "jot-*"+filepath.Ext(current.Filename))
+	if err != nil {
+		return err
+	}
+
+	path := f.Name()
+	f.Close()
+
+	defer os.Remove(path)
+
+	edited, err := c.editFile(ctx, path, current.Content)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if bytes.Equal(edited, current.Content) {
+			fmt.Fprintln(c.stderr, "no changes")
+
+			return nil
+		}
+
+		_, err := jc.UpdateText(ctx, o.key, password, edited, current.ETag)
+		if err == nil {
+			fmt.Fprintln(c.stdout, o.url())
+
+			return nil
+		}
+
+		if !errors.Is(err, jotclient.ErrETagMismatch) {
+			return err
+		}
+
+		latest, err := jc.GetText(ctx, o.key)
+		if err != nil {
+			return err
+		}
+
+		// the texts are the user's own, so they're compared however long it
+		// takes.
+		merged, ok, err := diff.Merge(
+			diff.SplitLines(string(current.Content)),
+			diff.SplitLines(string(latest.Content)),
+			diff.SplitLines(string(edited)),
+			"current", "yours", 0,
+		)
+		if err != nil {
+			return err
+		}
+
+		current, edited = latest, []byte(strings.Join(merged, ""))
+
+		if ok {
+			fmt.Fprintln(c.stderr, "the jot changed while you were editing it, merged your changes into it")
+
+			continue
+		}
+
+		fmt.Fprintln(c.stderr, "the jot changed while you were editing it, resolve the conflicts and save again")
+
+		if edited, err = c.editFile(ctx, path, edited); err != nil {
+			return err
+		}
+	}
+}
+
+// editFile writes content to the file at path, lets the user edit it and
+// returns what they saved.
+func (c *cli) editFile(ctx context.Context, path string, content []byte) ([]byte, error) {
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return nil, err
+	}
+
+	if err := c.edit(ctx, path); err != nil {
+		return nil, fmt.Errorf("editor failed, the jot wasn't changed: %w", err)
+	}
+
+	return os.ReadFile(path)
+}
+
+// rm deletes a jot or gallery and forgets its password.
+func (c *cli) rm(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("rm")
+	given := fs.String("password", "", "password of the jot, instead of the one in the vault")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+
+		return errors.New("rm takes one url")
+	}
+
+	o, err := parseObject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	password, err := c.password(o, *given)
+	if err != nil {
+		return err
+	}
+
+	jc, err := c.client(o.base)
+	if err != nil {
+		return err
+	}
+
+	if o.route == "img" {
+		err = jc.DeleteGallery(ctx, o.key, password)
+	} else {
+		err = jc.DeleteText(ctx, o.key, password)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.vault.Remove(o.url())
+}
+
+// img uploads images into a new gallery and prints its URL.
+func (c *cli) img(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("img")
+	server := fs.String("server", c.server, "url of the jot server")
+	key := fs.String("key", "", "create the gallery under this key")
+	ttl := fs.Duration("ttl", 0, "delete the gallery after this long")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+
+		return errors.New("img takes at least one file")
+	}
+
+	images := make([]jotclient.ImageFile, 0, fs.NArg())
+
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		images = append(images, jotclient.ImageFile{Name: filepath.Base(name), Content: f})
+	}
+
+	jc, err := c.client(*server)
+	if err != nil {
+		return err
+	}
+
+	created, err := jc.UploadImages(ctx, images, &jotclient.CreateOptions{Key: *key, TTL: *ttl})
+	if err != nil {
+		return err
+	}
+
+	if err := c.remember(created); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout, created.URL)
+
+	return nil
+}
+
+// runEditor returns an edit func that opens files in editor, a command line
+// that may have arguments of its own, such as "code --wait".
+func runEditor(editor string, stdin io.Reader, stdout, stderr io.Writer) func(context.Context, string) error {
+	return func(ctx context.Context, path string) error {
+		cmd := exec.CommandContext(ctx, "sh", "-c", editor+` "$@"`, editor, path)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+
+		return cmd.Run()
+	}
+}
+
+// editor returns the editor the user chose, like git does.
+func editor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := os.Getenv(env); e != "" {
+			return e
+		}
+	}
+
+	return defaultEditor
+}
+
+// Main runs the client command named by os.Args[1]. New jots are created on
+// the server at JOT_SERVER.
+func Main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := func() error {
+		path, err := vault.DefaultPath()
+		if err != nil {
+			return err
+		}
+
+		v, err := vault.Open(path)
+		if err != nil {
+			return err
+		}
+
+		server := os.Getenv("JOT_SERVER")
+		if server == "" {
+			server = defaultServer
+		}
+
+		c := &cli{
+			stdin:      os.Stdin,
+			stdout:     os.Stdout,
+			stderr:     os.Stderr,
+			server:     server,
+			vault:      v,
+			httpClient: http.DefaultClient,
+			edit:       runEditor(editor(), os.Stdin, os.Stdout, os.Stderr),
+		}
+
+		return c.run(ctx, os.Args[1:])
+	}()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jot: %s\n", err)
+		os.Exit(1)
+	}
+}
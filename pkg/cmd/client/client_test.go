@@ -0,0 +1,278 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jotclient "github.com/kyleterry/jot/pkg/client"
+	"github.com/kyleterry/jot/pkg/testutil/testserver"
+	"github.com/kyleterry/jot/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+// testCLI runs commands against a test server with an empty vault.
+type testCLI struct {
+	*cli
+	t      *testing.T
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+// exec runs a command and returns what it printed to stdout.
+func (tc *testCLI) exec(stdin string, args ...string) (string, error) {
+	tc.stdout.Reset()
+	tc.stderr.Reset()
+	tc.stdin = strings.NewReader(stdin)
+
+	err := tc.run(context.Background(), args)
+
+	return tc.stdout.String(), err
+}
+
+func newTestCLI(t *testing.T) *testCLI {
+	ts := testserver.New(t)
+
+	v, err := vault.Open(filepath.Join(t.TempDir(), vault.FileName))
+	require.NoError(t, err)
+
+	tc := &testCLI{t: t, stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}}
+	tc.cli = &cli{
+		stdout:     tc.stdout,
+		stderr:     tc.stderr,
+		server:     ts.URL,
+		vault:      v,
+		httpClient: ts.Client(),
+		edit: func(context.Context, string) error {
+			t.Fatal("unexpected edit")
+
+			return nil
+		},
+	}
+
+	return tc
+}
+
+// editWith makes the editor replace the file's content with what fn returns.
+func (tc *testCLI) editWith(fn func(content string) string) {
+	tc.edit = func(_ context.Context, path string) error {
+		content, err := os.ReadFile(path)
+		require.NoError(tc.t, err)
+
+		return os.WriteFile(path, []byte(fn(string(content))), 0o600)
+	}
+}
+
+func TestCLIText(t *testing.T) {
+	tc := newTestCLI(t)
+
+	out, err := tc.exec("hello\n", "push")
+	require.NoError(t, err)
+
+	url := strings.TrimSpace(out)
+	require.True(t, strings.HasPrefix(url, tc.server+"/txt/"))
+
+	o, err := parseObject(url)
+	require.NoError(t, err)
+
+	_, ok := tc.vault.Get(url)
+	require.True(t, ok)
+
+	out, err = tc.exec("", "get", url)
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", out)
+
+	t.Run("push a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "main.go")
+		require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0o600))
+
+		out, err := tc.exec("", "push", "-key", "my-main", path)
+		require.NoError(t, err)
+		require.Equal(t, tc.server+"/txt/my-main\n", out)
+
+		jc, err := tc.client(tc.server)
+		require.NoError(t, err)
+
+		text, err := jc.GetText(context.Background(), "my-main")
+		require.NoError(t, err)
+		require.Equal(t, "main.go", text.Filename)
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		tc.editWith(func(content string) string {
+			return content + "world\n"
+		})
+
+		out, err := tc.exec("", "edit", url)
+		require.NoError(t, err)
+		require.Equal(t, url+"\n", out)
+
+		out, err = tc.exec("", "get", url)
+		require.NoError(t, err)
+		require.Equal(t, "hello\nworld\n", out)
+	})
+
+	t.Run("edit without changes", func(t *testing.T) {
+		tc.editWith(func(content string) string {
+			return content
+		})
+
+		out, err := tc.exec("", "edit", url)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		require.Equal(t, "no changes\n", tc.stderr.String())
+	})
+
+	t.Run("edited by someone else", func(t *testing.T) {
+		jc, err := tc.client(tc.server)
+		require.NoError(t, err)
+
+		password, _ := tc.vault.Get(url)
+
+		// somebody changes the first line while the editor is open, and the
+		// user appends a line, so both changes are kept.
+		tc.editWith(func(content string) string {
+			_, err := jc.UpdateText(context.Background(), o.key, password, []byte("howdy\nworld\n"), "")
+			require.NoError(t, err)
+
+			return content + "again\n"
+		})
+
+		_, err = tc.exec("", "edit", url)
+		require.NoError(t, err)
+		require.Contains(t, tc.stderr.String(), "merged your changes")
+
+		out, err := tc.exec("", "get", url)
+		require.NoError(t, err)
+		require.Equal(t, "howdy\nworld\nagain\n", out)
+
+		// now both change the same line, so the editor opens again with the
+		// conflict for the user to resolve.
+		edits := 0
+		tc.editWith(func(content string) string {
+			edits++
+
+			if edits == 1 {
+				_, err := jc.UpdateText(context.Background(), o.key, password, []byte("hi\nworld\nagain\n"), "")
+				require.NoError(t, err)
+
+				return strings.Replace(content, "howdy", "hey", 1)
+			}
+
+			require.Contains(t, content, "<<<<<<< current")
+			require.Contains(t, content, ">>>>>>> yours")
+
+			return "hey there\nworld\nagain\n"
+		})
+
+		_, err = tc.exec("", "edit", url)
+		require.NoError(t, err)
+		require.Equal(t, 2, edits)
+		require.Contains(t, tc.stderr.String(), "resolve the conflicts")
+
+		out, err = tc.exec("", "get", url)
+		require.NoError(t, err)
+		require.Equal(t, "hey there\nworld\nagain\n", out)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		content := string([]byte{0xff, 0xfe, 0x00, 'j', 'o', 't', 0x80, '\n'})
+
+		out, err := tc.exec(content, "push")
+		require.NoError(t, err)
+
+		binaryURL := strings.TrimSpace(out)
+
+		out, err = tc.exec("", "get", binaryURL)
+		require.NoError(t, err)
+		require.Equal(t, content, out)
+
+		tc.editWith(func(edited string) string {
+			require.Equal(t, content, edited)
+
+			return edited + "\xc3\x28"
+		})
+
+		_, err = tc.exec("", "edit", binaryURL)
+		require.NoError(t, err)
+
+		out, err = tc.exec("", "get", binaryURL)
+		require.NoError(t, err)
+		require.Equal(t, content+"\xc3\x28", out)
+	})
+
+	t.Run("no password", func(t *testing.T) {
+		other := tc.server + "/txt/my-main"
+		require.NoError(t, tc.vault.Remove(other))
+
+		_, err := tc.exec("", "rm", other)
+		require.ErrorContains(t, err, "no password")
+
+		_, err = tc.exec("", "rm", "-password", "wrong", other)
+		require.ErrorIs(t, err, jotclient.ErrInvalidPassword)
+	})
+
+	_, err = tc.exec("", "rm", url)
+	require.NoError(t, err)
+
+	_, ok = tc.vault.Get(url)
+	require.False(t, ok)
+
+	_, err = tc.exec("", "get", url)
+	require.ErrorIs(t, err, jotclient.ErrNotFound)
+}
+
+func TestCLIImages(t *testing.T) {
+	tc := newTestCLI(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	path := filepath.Join(t.TempDir(), "pixel.png")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	out, err := tc.exec("", "img", path)
+	require.NoError(t, err)
+
+	url := strings.TrimSpace(out)
+	require.True(t, strings.HasPrefix(url, tc.server+"/img/"))
+
+	out, err = tc.exec("", "get", url)
+	require.NoError(t, err)
+	require.Equal(t, url+"/pixel.png\n", out)
+
+	_, err = tc.exec("", "edit", url)
+	require.ErrorContains(t, err, "only text jots")
+
+	_, err = tc.exec("", "rm", url+"/pixel.png")
+	require.NoError(t, err)
+
+	_, err = tc.exec("", "get", url)
+	require.ErrorIs(t, err, jotclient.ErrNotFound)
+}
+
+func TestCLIUsage(t *testing.T) {
+	tc := newTestCLI(t)
+
+	_, err := tc.exec("")
+	require.ErrorContains(t, err, "missing command")
+
+	_, err = tc.exec("", "nope")
+	require.ErrorContains(t, err, `unknown command "nope"`)
+
+	_, err = tc.exec("", "push", "-h")
+	require.NoError(t, err)
+	require.Contains(t, tc.stderr.String(), "jot push")
+
+	_, err = tc.exec("", "get", "https://example.com/other/abc")
+	require.ErrorContains(t, err, "not a jot url")
+}
@@ -0,0 +1,54 @@
+// Package encrypt is the one-shot command that encrypts an existing plaintext
+// data dir in place, so a server can start with JOT_ENCRYPT turned on.
+package encrypt
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
+	textfs "github.com/kyleterry/jot/pkg/jot/store/backends"
+	"github.com/kyleterry/jot/pkg/search"
+)
+
+// migrator holds what the migration reads and writes.
+type migrator struct {
+	DataDir config.DataDir
+	Cipher  *encryption.Cipher
+	Text    *textfs.Filesystem
+	Images  *imagefs.Backend
+}
+
+// Main encrypts every jot, revision and image in the data dir that isn't
+// encrypted yet. The server must not be running while it does.
+func Main() {
+	m, err := initMigrator()
+	if err != nil {
+		slog.Error("failed to initialize migration", "error", err)
+		os.Exit(1)
+	}
+
+	jots, err := m.Cipher.MigrateText(m.Text)
+	if err != nil {
+		slog.Error("failed to encrypt jots", "error", err, "encrypted", jots)
+		os.Exit(1)
+	}
+
+	images, err := m.Cipher.MigrateImages(context.Background(), m.Images)
+	if err != nil {
+		slog.Error("failed to encrypt images", "error", err, "encrypted", images)
+		os.Exit(1)
+	}
+
+	// the search index holds the words of jots, so an encrypting server
+	// keeps it in memory instead.
+	if err := search.Delete(search.IndexPath(m.DataDir)); err != nil {
+		slog.Error("failed to remove search index", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("encrypted data dir", "jots", jots, "images", images)
+}
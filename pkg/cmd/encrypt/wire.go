@@ -0,0 +1,48 @@
+//go:build wireinject
+
+package encrypt
+
+import (
+	"github.com/google/wire"
+	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
+	textfs "github.com/kyleterry/jot/pkg/jot/store/backends"
+)
+
+func provideMasterPassword(cfg *config.Config) auth.MasterPassword {
+	return cfg.MasterPassword
+}
+
+func provideSeedFileLocation(cfg *config.Config) auth.SeedFileLocation {
+	return cfg.SeedFileLocation
+}
+
+func provideDataDir(cfg *config.Config) config.DataDir {
+	return cfg.DataDir
+}
+
+// provideCompression turns compression off, since the migration only writes
+// sealed content, which doesn't compress.
+func provideCompression() config.Compression {
+	return "none"
+}
+
+func initMigrator() (*migrator, error) {
+	panic(wire.Build(
+		config.ProviderSet,
+		provideMasterPassword,
+		provideSeedFileLocation,
+		provideDataDir,
+		provideCompression,
+		auth.DefaultSpec,
+		auth.NewSeedFile,
+		encryption.ProviderSet,
+		blob.ProviderSet,
+		textfs.ProviderSet,
+		imagefs.ProviderSet,
+		wire.Struct(new(migrator), "*"),
+	))
+}
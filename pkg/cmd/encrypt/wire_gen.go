@@ -0,0 +1,86 @@
+// Code generated by Wire. DO NOT EDIT.
+
+//go:generate go run -mod=mod github.com/google/wire/cmd/wire
+//go:build !wireinject
+// +build !wireinject
+
+package encrypt
+
+import (
+	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	"github.com/kyleterry/jot/pkg/image/backend/filesystem"
+	"github.com/kyleterry/jot/pkg/jot/store/backends"
+)
+
+// Injectors from wire.go:
+
+func initMigrator() (*migrator, error) {
+	configConfig, err := config.New()
+	if err != nil {
+		return nil, err
+	}
+	dataDir := provideDataDir(configConfig)
+	masterPassword := provideMasterPassword(configConfig)
+	seedFileLocation := provideSeedFileLocation(configConfig)
+	passwordSpec := auth.DefaultSpec()
+	seedFile, err := auth.NewSeedFile(masterPassword, seedFileLocation, passwordSpec)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := encryption.ProvideCipher(seedFile)
+	if err != nil {
+		return nil, err
+	}
+	filesystemOptions := backends.ProvideFilesystemOptions(dataDir)
+	compression := provideCompression()
+	options := &blob.Options{
+		StorageDir:  dataDir,
+		Compression: compression,
+	}
+	store, err := blob.New(options)
+	if err != nil {
+		return nil, err
+	}
+	backendsFilesystem, err := backends.NewFilesystem(filesystemOptions, store)
+	if err != nil {
+		return nil, err
+	}
+	options2 := &filesystem.Options{
+		StorageDir: dataDir,
+		Blobs:      store,
+	}
+	backend, err := filesystem.New(options2)
+	if err != nil {
+		return nil, err
+	}
+	encryptMigrator := &migrator{
+		DataDir: dataDir,
+		Cipher:  cipher,
+		Text:    backendsFilesystem,
+		Images:  backend,
+	}
+	return encryptMigrator, nil
+}
+
+// wire.go:
+
+func provideMasterPassword(cfg *config.Config) auth.MasterPassword {
+	return cfg.MasterPassword
+}
+
+func provideSeedFileLocation(cfg *config.Config) auth.SeedFileLocation {
+	return cfg.SeedFileLocation
+}
+
+func provideDataDir(cfg *config.Config) config.DataDir {
+	return cfg.DataDir
+}
+
+// provideCompression turns compression off, since the migration only writes
+// sealed content, which doesn't compress.
+func provideCompression() config.Compression {
+	return "none"
+}
@@ -6,19 +6,35 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/kyleterry/jot/pkg/compression"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/server"
+	"github.com/kyleterry/jot/pkg/store"
 )
 
+// app holds the long running parts of jot.
+type app struct {
+	Config *config.Config
+	Server *server.Server
+	Reaper *store.Reaper
+}
+
 func Main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
 
-	srv, err := initServer()
+	a, err := initApp()
 	if err != nil {
 		slog.Error("failed to initialize server", "error", err)
 		os.Exit(1)
 	}
 
-	cancelFn, errch := srv.Run(ctx)
+	warnEncrypted(a.Config)
+
+	go a.Reaper.Run(ctx)
+
+	cancelFn, errch := a.Server.Run(ctx)
 	defer cancelFn()
 
 	select {
@@ -33,3 +49,18 @@ func Main() {
 		}
 	}
 }
+
+// warnEncrypted logs what encryption turns off. Content is sealed for every
+// object with a random nonce, so it's never shared between objects and doesn't
+// compress, and provideCompression ignores JOT_COMPRESSION.
+func warnEncrypted(cfg *config.Config) {
+	if !cfg.Encrypt {
+		return
+	}
+
+	slog.Info("encryption is turned on, content isn't shared between jots and images")
+
+	if algorithm, err := compression.Parse(string(cfg.Compression)); err == nil && algorithm != compression.None {
+		slog.Warn("JOT_COMPRESSION is ignored while encryption is turned on", "compression", cfg.Compression)
+	}
+}
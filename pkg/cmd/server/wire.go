@@ -5,12 +5,18 @@ package server
 import (
 	"github.com/google/wire"
 	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
 	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	"github.com/kyleterry/jot/pkg/events"
 	"github.com/kyleterry/jot/pkg/id"
 	"github.com/kyleterry/jot/pkg/image"
+	imagebackend "github.com/kyleterry/jot/pkg/image/backend"
 	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
 	"github.com/kyleterry/jot/pkg/jot"
+	jotbackend "github.com/kyleterry/jot/pkg/jot/store"
 	textfs "github.com/kyleterry/jot/pkg/jot/store/backends"
+	"github.com/kyleterry/jot/pkg/search"
 	"github.com/kyleterry/jot/pkg/server"
 	"github.com/kyleterry/jot/pkg/store"
 	"github.com/kyleterry/jot/pkg/text"
@@ -28,22 +34,90 @@ func provideDataDir(cfg *config.Config) config.DataDir {
 	return cfg.DataDir
 }
 
-func initServer() (*server.Server, error) {
+// provideCompression returns what new blobs are compressed with. Sealed
+// content doesn't compress, so compression is off when encryption is on.
+func provideCompression(cfg *config.Config) config.Compression {
+	if cfg.Encrypt {
+		return "none"
+	}
+
+	return cfg.Compression
+}
+
+// provideTextBackend wraps the jot filesystem so content is encrypted when
+// encryption is turned on. Sealed content is never the same twice, so it isn't
+// deduplicated, and it isn't compressed; see warnEncrypted.
+func provideTextBackend(cfg *config.Config, fs *textfs.Filesystem, c *encryption.Cipher) jotbackend.Backend {
+	if !cfg.Encrypt {
+		return fs
+	}
+
+	return encryption.NewTextBackend(fs, c)
+}
+
+// provideImageBackend wraps the image filesystem so images are encrypted when
+// encryption is turned on, like provideTextBackend does for jots.
+func provideImageBackend(cfg *config.Config, fs *imagefs.Backend, c *encryption.Cipher) imagebackend.Interface {
+	if !cfg.Encrypt {
+		return fs
+	}
+
+	return encryption.NewImageBackend(fs, c)
+}
+
+// provideSearchIndex opens the search index in the data dir and builds it from
+// every jot when there's none yet. The index holds the words of jots, so it's
+// kept in memory and built on every start when encryption is turned on.
+func provideSearchIndex(cfg *config.Config, backend jotbackend.Backend) (*search.Index, error) {
+	if cfg.Encrypt {
+		idx := search.New("")
+
+		return idx, idx.Rebuild(backend)
+	}
+
+	idx, ok, err := search.Open(search.IndexPath(cfg.DataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if err := idx.Rebuild(backend); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+func provideReaper(cfg *config.Config, ts *jot.TextStore, is *image.Store) *store.Reaper {
+	return store.NewReaper(cfg.ReapInterval, ts, is)
+}
+
+func initApp() (*app, error) {
 	panic(wire.Build(
 		config.ProviderSet,
 		provideMasterPassword,
 		provideSeedFileLocation,
 		provideDataDir,
+		provideCompression,
 		auth.ProviderSet,
 		wire.Bind(new(auth.PasswordManagerService), new(*auth.PasswordManager)),
 		id.ProviderSet,
+		events.ProviderSet,
 		store.ProviderSet,
-		textfs.BoundProviderSet,
+		encryption.ProviderSet,
+		blob.ProviderSet,
+		textfs.ProviderSet,
+		provideTextBackend,
+		provideSearchIndex,
 		jot.ProviderSet,
 		wire.Bind(new(text.StoreService), new(*jot.TextStore)),
-		imagefs.BoundProviderSet,
+		imagefs.ProviderSet,
+		provideImageBackend,
 		image.ProviderSet,
 		wire.Bind(new(image.StoreService), new(*image.Store)),
 		server.ProviderSet,
+		provideReaper,
+		wire.Struct(new(app), "*"),
 	))
 }
@@ -8,26 +8,41 @@ package server
 
 import (
 	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
 	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	"github.com/kyleterry/jot/pkg/events"
 	"github.com/kyleterry/jot/pkg/id"
 	"github.com/kyleterry/jot/pkg/image"
+	"github.com/kyleterry/jot/pkg/image/backend"
 	"github.com/kyleterry/jot/pkg/image/backend/filesystem"
 	"github.com/kyleterry/jot/pkg/jot"
+	store2 "github.com/kyleterry/jot/pkg/jot/store"
 	"github.com/kyleterry/jot/pkg/jot/store/backends"
+	"github.com/kyleterry/jot/pkg/search"
 	"github.com/kyleterry/jot/pkg/server"
 	"github.com/kyleterry/jot/pkg/store"
 )
 
 // Injectors from wire.go:
 
-func initServer() (*server.Server, error) {
+func initApp() (*app, error) {
 	configConfig, err := config.New()
 	if err != nil {
 		return nil, err
 	}
 	dataDir := provideDataDir(configConfig)
 	filesystemOptions := backends.ProvideFilesystemOptions(dataDir)
-	backendsFilesystem, err := backends.NewFilesystem(filesystemOptions)
+	compression := provideCompression(configConfig)
+	options := &blob.Options{
+		StorageDir:  dataDir,
+		Compression: compression,
+	}
+	blobStore, err := blob.New(options)
+	if err != nil {
+		return nil, err
+	}
+	backendsFilesystem, err := backends.NewFilesystem(filesystemOptions, blobStore)
 	if err != nil {
 		return nil, err
 	}
@@ -38,29 +53,51 @@ func initServer() (*server.Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	cipher, err := encryption.ProvideCipher(seedFile)
+	if err != nil {
+		return nil, err
+	}
+	backend := provideTextBackend(configConfig, backendsFilesystem, cipher)
 	v := auth.ProvideAllSeedFiles(seedFile)
 	passwordManager := auth.NewPasswordManager(v...)
 	idManager, err := id.NewIDManager()
 	if err != nil {
 		return nil, err
 	}
-	options := &store.Options{
+	broker := events.NewBroker()
+	index, err := provideSearchIndex(configConfig, backend)
+	if err != nil {
+		return nil, err
+	}
+	storeOptions := &store.Options{
 		PasswordManager: passwordManager,
 		IDManager:       idManager,
+		Events:          broker,
+		Search:          index,
 	}
-	textStore := jot.NewStore(backendsFilesystem, options)
-	jotHandler := server.NewJotHandler(configConfig, textStore, passwordManager)
+	textStore := jot.NewStore(backend, storeOptions)
+	jotHandler := server.NewJotHandler(configConfig, textStore, passwordManager, broker)
 	options2 := &filesystem.Options{
 		StorageDir: dataDir,
+		Blobs:      blobStore,
 	}
-	backend, err := filesystem.New(options2)
+	filesystemBackend, err := filesystem.New(options2)
 	if err != nil {
 		return nil, err
 	}
-	imageStore := image.NewStore(backend, options)
+	backendInterface := provideImageBackend(configConfig, filesystemBackend, cipher)
+	imageStore := image.NewStore(backendInterface, storeOptions)
 	imageHandler := server.NewImageHandler(configConfig, imageStore, passwordManager)
-	serverServer := server.New(configConfig, jotHandler, imageHandler)
-	return serverServer, nil
+	adminHandler := server.NewAdminHandler(configConfig, textStore, index)
+	apiHandler := server.NewAPIHandler(configConfig, jotHandler, imageHandler)
+	serverServer := server.New(configConfig, jotHandler, imageHandler, adminHandler, apiHandler)
+	reaper := provideReaper(configConfig, textStore, imageStore)
+	serverApp := &app{
+		Config: configConfig,
+		Server: serverServer,
+		Reaper: reaper,
+	}
+	return serverApp, nil
 }
 
 // wire.go:
@@ -76,3 +113,62 @@ func provideSeedFileLocation(cfg *config.Config) auth.SeedFileLocation {
 func provideDataDir(cfg *config.Config) config.DataDir {
 	return cfg.DataDir
 }
+
+// provideCompression returns what new blobs are compressed with. Sealed
+// content doesn't compress, so compression is off when encryption is on.
+func provideCompression(cfg *config.Config) config.Compression {
+	if cfg.Encrypt {
+		return "none"
+	}
+
+	return cfg.Compression
+}
+
+// provideTextBackend wraps the jot filesystem so content is encrypted when
+// encryption is turned on. Sealed content is never the same twice, so it isn't
+// deduplicated, and it isn't compressed; see warnEncrypted.
+func provideTextBackend(cfg *config.Config, fs *backends.Filesystem, c *encryption.Cipher) store2.Backend {
+	if !cfg.Encrypt {
+		return fs
+	}
+
+	return encryption.NewTextBackend(fs, c)
+}
+
+// provideImageBackend wraps the image filesystem so images are encrypted when
+// encryption is turned on, like provideTextBackend does for jots.
+func provideImageBackend(cfg *config.Config, fs *filesystem.Backend, c *encryption.Cipher) backend.Interface {
+	if !cfg.Encrypt {
+		return fs
+	}
+
+	return encryption.NewImageBackend(fs, c)
+}
+
+// provideSearchIndex opens the search index in the data dir and builds it from
+// every jot when there's none yet. The index holds the words of jots, so it's
+// kept in memory and built on every start when encryption is turned on.
+func provideSearchIndex(cfg *config.Config, backend2 store2.Backend) (*search.Index, error) {
+	if cfg.Encrypt {
+		idx := search.New("")
+
+		return idx, idx.Rebuild(backend2)
+	}
+
+	idx, ok, err := search.Open(search.IndexPath(cfg.DataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if err := idx.Rebuild(backend2); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+func provideReaper(cfg *config.Config, ts *jot.TextStore, is *image.Store) *store.Reaper {
+	return store.NewReaper(cfg.ReapInterval, ts, is)
+}
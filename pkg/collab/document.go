@@ -0,0 +1,156 @@
+package collab
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+const (
+	// maxHistory is how many operations a document keeps to transform late
+	// operations against. Editors that fall further behind have to start
+	// again from the current text.
+	maxHistory = 1000
+	// subscriberBuffer is how many changes a subscriber can fall behind by
+	// before its subscription is ended.
+	subscriberBuffer = 64
+)
+
+// Change is an operation applied to a document. Version is the version of
+// the document it made, and Client the editor that sent it, if any.
+type Change struct {
+	Version int       `json:"version"`
+	Op      Operation `json:"op"`
+	Client  string    `json:"client,omitempty"`
+}
+
+// Document is a text several editors change at once. Every applied operation
+// makes a new version of it.
+type Document struct {
+	mu      sync.Mutex
+	text    []uint16
+	version int
+	// history holds the operations that made the last len(history) versions.
+	history     []Operation
+	subscribers map[chan Change]struct{}
+	closed      bool
+}
+
+// Apply applies an operation made to version of the document and tells every
+// subscriber. Operations made to older versions are transformed against the
+// ones applied since. It returns the change as it was applied.
+func (d *Document) Apply(client string, version int, op Operation) (Change, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return Change{}, errors.NewBadRequestError("the document is closed")
+	}
+
+	if version > d.version || version < d.version-len(d.history) {
+		return Change{}, errors.NewBadRequestError(fmt.Sprintf("unknown document version %d", version))
+	}
+
+	for _, applied := range d.history[len(d.history)-(d.version-version):] {
+		var err error
+
+		op, _, err = Transform(op, applied)
+		if err != nil {
+			return Change{}, err
+		}
+	}
+
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return Change{}, err
+	}
+
+	d.text = text
+	d.version++
+
+	d.history = append(d.history, op)
+	if len(d.history) > maxHistory {
+		d.history = d.history[len(d.history)-maxHistory:]
+	}
+
+	change := Change{Version: d.version, Op: op, Client: client}
+
+	for ch := range d.subscribers {
+		select {
+		case ch <- change:
+		default:
+			// the subscriber would miss this change, so it has to start
+			// again.
+			delete(d.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return change, nil
+}
+
+// Text returns the current text and its version.
+func (d *Document) Text() (string, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return string(utf16.Decode(d.text)), d.version
+}
+
+// Subscribe returns the current text and its version, and a channel of the
+// changes applied after it. The channel is closed when the subscriber falls
+// too far behind or the document is closed. cancel ends the subscription.
+func (d *Document) Subscribe() (text string, version int, changes <-chan Change, cancel func()) {
+	ch := make(chan Change, subscriberBuffer)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		close(ch)
+	} else {
+		d.subscribers[ch] = struct{}{}
+	}
+
+	var once sync.Once
+
+	return string(utf16.Decode(d.text)), d.version, ch, func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			if _, ok := d.subscribers[ch]; ok {
+				delete(d.subscribers, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// Close ends every subscription. Operations can't be applied to a closed
+// document.
+func (d *Document) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	d.closed = true
+
+	for ch := range d.subscribers {
+		delete(d.subscribers, ch)
+		close(ch)
+	}
+}
+
+// NewDocument returns a document holding text at version 0.
+func NewDocument(text string) *Document {
+	return &Document{
+		text:        utf16.Encode([]rune(text)),
+		subscribers: make(map[chan Change]struct{}),
+	}
+}
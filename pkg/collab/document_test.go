@@ -0,0 +1,68 @@
+package collab_test
+
+import (
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/collab"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	doc := collab.NewDocument("hello")
+
+	text, version, changes, cancel := doc.Subscribe()
+	defer cancel()
+
+	require.Equal(t, "hello", text)
+	require.Equal(t, 0, version)
+
+	// two editors change version 0 at the same time
+	first, err := doc.Apply("a", 0, collab.Operation{{Insert: "oh, "}, {Retain: 5}})
+	require.NoError(t, err)
+	require.Equal(t, 1, first.Version)
+
+	second, err := doc.Apply("b", 0, collab.Operation{{Retain: 5}, {Insert: " world"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, second.Version)
+	require.Equal(t, collab.Operation{{Retain: 9}, {Insert: " world"}}, second.Op)
+
+	text, version = doc.Text()
+	require.Equal(t, "oh, hello world", text)
+	require.Equal(t, 2, version)
+
+	require.Equal(t, first, <-changes)
+	require.Equal(t, second, <-changes)
+
+	_, err = doc.Apply("a", 3, collab.Operation{{Retain: 15}})
+	require.Error(t, err)
+
+	_, err = doc.Apply("a", 2, collab.Operation{{Retain: 3}})
+	require.Error(t, err)
+
+	doc.Close()
+
+	_, ok := <-changes
+	require.False(t, ok)
+
+	_, err = doc.Apply("a", 2, collab.Operation{{Retain: 15}})
+	require.Error(t, err)
+}
+
+func TestDocumentSlowSubscriber(t *testing.T) {
+	doc := collab.NewDocument("")
+
+	_, _, changes, cancel := doc.Subscribe()
+	defer cancel()
+
+	for version := range 100 {
+		_, err := doc.Apply("a", version, collab.Operation{{Retain: version}, {Insert: "x"}})
+		require.NoError(t, err)
+	}
+
+	var received int
+	for range changes {
+		received++
+	}
+
+	require.Less(t, received, 100)
+}
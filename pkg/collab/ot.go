@@ -0,0 +1,290 @@
+// Package collab lets several people edit the same text at once. Edits are
+// sent as operations against the version of the text they were made to, and
+// operations made to older versions are transformed against the ones applied
+// since, so every editor ends up with the same text.
+//
+// Positions and lengths are counted in UTF-16 code units, which is how
+// browsers count the length of strings.
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+// Component is one step of an Operation. Exactly one of its fields is set:
+// Retain skips over text, Insert adds text and Delete removes text.
+type Component struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+// MarshalJSON encodes a retain as a positive number, a delete as a negative
+// number and an insert as a string.
+func (c Component) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Insert != "":
+		return json.Marshal(c.Insert)
+	case c.Delete > 0:
+		return json.Marshal(-c.Delete)
+	default:
+		return json.Marshal(c.Retain)
+	}
+}
+
+func (c *Component) UnmarshalJSON(raw []byte) error {
+	var n int
+	if err := json.Unmarshal(raw, &n); err == nil {
+		switch {
+		case n > 0:
+			*c = Component{Retain: n}
+		case n < 0:
+			*c = Component{Delete: -n}
+		default:
+			return fmt.Errorf("empty operation component")
+		}
+
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("operation components are numbers or strings: %s", raw)
+	}
+
+	if s == "" {
+		return fmt.Errorf("empty operation component")
+	}
+
+	*c = Component{Insert: s}
+
+	return nil
+}
+
+// Operation is an edit to a whole text. Its components walk over the text
+// from the start and must cover all of it.
+type Operation []Component
+
+// MarshalJSON encodes operations that do nothing as an empty list rather than
+// null.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]Component(o))
+}
+
+// retain appends a retain, joining it with the last component if that's a
+// retain too.
+func (o Operation) retain(n int) Operation {
+	if n == 0 {
+		return o
+	}
+
+	if len(o) > 0 && o[len(o)-1].Retain > 0 {
+		o[len(o)-1].Retain += n
+
+		return o
+	}
+
+	return append(o, Component{Retain: n})
+}
+
+func (o Operation) insert(s string) Operation {
+	if s == "" {
+		return o
+	}
+
+	if len(o) > 0 && o[len(o)-1].Insert != "" {
+		o[len(o)-1].Insert += s
+
+		return o
+	}
+
+	return append(o, Component{Insert: s})
+}
+
+func (o Operation) delete(n int) Operation {
+	if n == 0 {
+		return o
+	}
+
+	if len(o) > 0 && o[len(o)-1].Delete > 0 {
+		o[len(o)-1].Delete += n
+
+		return o
+	}
+
+	return append(o, Component{Delete: n})
+}
+
+// BaseLength returns the length of the text the operation applies to.
+func (o Operation) BaseLength() int {
+	var n int
+
+	for _, c := range o {
+		n += c.Retain + c.Delete
+	}
+
+	return n
+}
+
+// Apply returns text with the operation applied to it.
+func (o Operation) Apply(text []uint16) ([]uint16, error) {
+	if o.BaseLength() != len(text) {
+		return nil, errors.NewBadRequestError(fmt.Sprintf("operation is for a text of length %d, not %d", o.BaseLength(), len(text)))
+	}
+
+	out := make([]uint16, 0, len(text))
+
+	var pos int
+
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			out = append(out, text[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Delete > 0:
+			pos += c.Delete
+		default:
+			out = append(out, utf16.Encode([]rune(c.Insert))...)
+		}
+	}
+
+	return out, nil
+}
+
+// Transform takes two operations made to the same text and returns versions
+// of them that apply after each other: a' after b and b' after a both give
+// the same text. When both insert at the same place, a's insert goes first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, errors.NewBadRequestError("operations are for texts of different lengths")
+	}
+
+	var (
+		aPrime, bPrime Operation
+		i, j           int
+		ca, cb         Component
+	)
+
+	next := func(o Operation, i *int) Component {
+		if *i >= len(o) {
+			return Component{}
+		}
+
+		*i++
+
+		return o[*i-1]
+	}
+
+	ca, cb = next(a, &i), next(b, &j)
+
+	for ca != (Component{}) || cb != (Component{}) {
+		if ca.Insert != "" {
+			aPrime = aPrime.insert(ca.Insert)
+			bPrime = bPrime.retain(length(ca.Insert))
+			ca = next(a, &i)
+
+			continue
+		}
+
+		if cb.Insert != "" {
+			aPrime = aPrime.retain(length(cb.Insert))
+			bPrime = bPrime.insert(cb.Insert)
+			cb = next(b, &j)
+
+			continue
+		}
+
+		if ca == (Component{}) || cb == (Component{}) {
+			return nil, nil, errors.NewBadRequestError("operation is shorter than the text")
+		}
+
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			n := min(ca.Retain, cb.Retain)
+			aPrime, bPrime = aPrime.retain(n), bPrime.retain(n)
+			ca.Retain, cb.Retain = ca.Retain-n, cb.Retain-n
+		case ca.Delete > 0 && cb.Delete > 0:
+			// both deleted the same text
+			n := min(ca.Delete, cb.Delete)
+			ca.Delete, cb.Delete = ca.Delete-n, cb.Delete-n
+		case ca.Delete > 0:
+			n := min(ca.Delete, cb.Retain)
+			aPrime = aPrime.delete(n)
+			ca.Delete, cb.Retain = ca.Delete-n, cb.Retain-n
+		default:
+			n := min(ca.Retain, cb.Delete)
+			bPrime = bPrime.delete(n)
+			ca.Retain, cb.Delete = ca.Retain-n, cb.Delete-n
+		}
+
+		if ca == (Component{}) {
+			ca = next(a, &i)
+		}
+
+		if cb == (Component{}) {
+			cb = next(b, &j)
+		}
+	}
+
+	return aPrime, bPrime, nil
+}
+
+// Diff returns an operation that turns a into b by replacing what's between
+// their common start and end.
+func Diff(a, b []uint16) Operation {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	// the replaced text mustn't split a surrogate pair, or the inserted
+	// half of it couldn't be sent as a string.
+	if prefix > 0 && isHighSurrogate(a[prefix-1]) {
+		prefix--
+	}
+
+	if suffix > 0 && isLowSurrogate(a[len(a)-suffix]) {
+		suffix--
+	}
+
+	var o Operation
+
+	o = o.retain(prefix)
+	o = o.delete(len(a) - prefix - suffix)
+	o = o.insert(string(utf16.Decode(b[prefix : len(b)-suffix])))
+	o = o.retain(suffix)
+
+	return o
+}
+
+func isHighSurrogate(u uint16) bool {
+	return 0xd800 <= u && u < 0xdc00
+}
+
+func isLowSurrogate(u uint16) bool {
+	return 0xdc00 <= u && u < 0xe000
+}
+
+// length returns the length of s in UTF-16 code units.
+func length(s string) int {
+	var n int
+
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
@@ -0,0 +1,133 @@
+package collab_test
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/kyleterry/jot/pkg/collab"
+	"github.com/stretchr/testify/require"
+)
+
+func text(s string) []uint16 {
+	return utf16.Encode([]rune(s))
+}
+
+// randomOperation returns a random edit of a text of length n.
+func randomOperation(r *rand.Rand, n int) collab.Operation {
+	var op collab.Operation
+
+	for pos := 0; pos < n; {
+		step := 1 + r.IntN(n-pos)
+
+		switch r.IntN(3) {
+		case 0:
+			op = append(op, collab.Component{Retain: step})
+			pos += step
+		case 1:
+			op = append(op, collab.Component{Delete: step})
+			pos += step
+		default:
+			op = append(op, collab.Component{Insert: []string{"x", "é", "😀"}[r.IntN(3)]})
+		}
+	}
+
+	if r.IntN(2) == 0 {
+		op = append(op, collab.Component{Insert: "end"})
+	}
+
+	return op
+}
+
+func TestTransform(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	doc := text("the quick brown fox")
+
+	for range 1000 {
+		a, b := randomOperation(r, len(doc)), randomOperation(r, len(doc))
+
+		aPrime, bPrime, err := collab.Transform(a, b)
+		require.NoError(t, err)
+
+		afterA, err := a.Apply(doc)
+		require.NoError(t, err)
+
+		afterB, err := b.Apply(doc)
+		require.NoError(t, err)
+
+		ab, err := bPrime.Apply(afterA)
+		require.NoError(t, err)
+
+		ba, err := aPrime.Apply(afterB)
+		require.NoError(t, err)
+
+		require.Equal(t, string(utf16.Decode(ab)), string(utf16.Decode(ba)))
+	}
+}
+
+func TestTransformInsertOrder(t *testing.T) {
+	a := collab.Operation{{Retain: 1}, {Insert: "a"}}
+	b := collab.Operation{{Retain: 1}, {Insert: "b"}}
+
+	_, bPrime, err := collab.Transform(a, b)
+	require.NoError(t, err)
+
+	afterA, err := a.Apply(text("x"))
+	require.NoError(t, err)
+
+	out, err := bPrime.Apply(afterA)
+	require.NoError(t, err)
+	require.Equal(t, "xab", string(utf16.Decode(out)))
+}
+
+func TestApplyLength(t *testing.T) {
+	_, err := collab.Operation{{Retain: 2}}.Apply(text("abc"))
+	require.Error(t, err)
+}
+
+func TestOperationJSON(t *testing.T) {
+	op := collab.Operation{{Retain: 3}, {Insert: "hi"}, {Delete: 2}}
+
+	raw, err := json.Marshal(op)
+	require.NoError(t, err)
+	require.JSONEq(t, `[3, "hi", -2]`, string(raw))
+
+	var decoded collab.Operation
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	require.Equal(t, op, decoded)
+
+	require.Error(t, json.Unmarshal([]byte(`[0]`), &decoded))
+	require.Error(t, json.Unmarshal([]byte(`[""]`), &decoded))
+	require.Error(t, json.Unmarshal([]byte(`[true]`), &decoded))
+
+	raw, err = json.Marshal(collab.Operation(nil))
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(raw))
+}
+
+func TestDiff(t *testing.T) {
+	cases := []struct{ a, b string }{
+		{"", ""},
+		{"", "abc"},
+		{"abc", ""},
+		{"hello world", "hello brave world"},
+		{"aaa", "aa"},
+		{"😀", "😃"},
+		{"x😀y", "x😃y"},
+	}
+
+	for _, c := range cases {
+		op := collab.Diff(text(c.a), text(c.b))
+
+		out, err := op.Apply(text(c.a))
+		require.NoError(t, err)
+		require.Equal(t, c.b, string(utf16.Decode(out)), "%q -> %q", c.a, c.b)
+
+		for _, comp := range op {
+			if comp.Insert != "" {
+				require.NotContains(t, comp.Insert, "�")
+			}
+		}
+	}
+}
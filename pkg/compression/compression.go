@@ -0,0 +1,341 @@
+// Package compression compresses stored objects inside the filesystem
+// backends. Compressed content starts with a short header naming the
+// algorithm it was compressed with, so every object records its own
+// compression and content written before compression was turned on, or with
+// another algorithm, still reads.
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+type Algorithm byte
+
+const (
+	None Algorithm = iota
+	Gzip
+	Zstd
+)
+
+var names = map[Algorithm]string{
+	None: "none",
+	Gzip: "gzip",
+	Zstd: "zstd",
+}
+
+// String returns the name of the algorithm, which is also its HTTP content
+// coding.
+func (a Algorithm) String() string {
+	if name, ok := names[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", byte(a))
+}
+
+// Parse returns the algorithm called name. An empty name is None.
+func Parse(name string) (Algorithm, error) {
+	if name == "" {
+		return None, nil
+	}
+
+	for a, n := range names {
+		if n == name {
+			return a, nil
+		}
+	}
+
+	return None, fmt.Errorf("unknown compression algorithm %q", name)
+}
+
+// magic starts the header of compressed content. It isn't valid UTF-8 text,
+// so plain jots are never mistaken for compressed ones.
+const magic = "\x00jotz"
+
+// HeaderSize is the length of the header: magic followed by the algorithm.
+const HeaderSize = len(magic) + 1
+
+// ReadHeader returns the algorithm content read from r was written with and
+// the length of its header. Content without a header is None and has no
+// header.
+func ReadHeader(r io.ReaderAt) (Algorithm, int64, error) {
+	head := make([]byte, HeaderSize)
+
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return None, 0, err
+	}
+
+	if n < HeaderSize || string(head[:len(magic)]) != magic {
+		return None, 0, nil
+	}
+
+	a := Algorithm(head[len(magic)])
+	if _, ok := names[a]; !ok {
+		return None, 0, errors.NewUnknownError(fmt.Sprintf("content is compressed with an unknown algorithm: %d", a))
+	}
+
+	return a, int64(HeaderSize), nil
+}
+
+// Encode writes content to w compressed with a, after its header. Content
+// that isn't compressed only gets a header when it starts like one, so it's
+// never mistaken for compressed content.
+func Encode(w io.Writer, content io.Reader, a Algorithm) error {
+	buf := bufio.NewReader(content)
+
+	if a == None {
+		if prefix, _ := buf.Peek(len(magic)); string(prefix) != magic {
+			_, err := buf.WriteTo(w)
+
+			return err
+		}
+	}
+
+	cw, err := NewWriter(w, a)
+	if err != nil {
+		return err
+	}
+
+	return copyClose(cw, buf)
+}
+
+// NewWriter writes the header for a to w and returns a writer that compresses
+// what's written to it into w. Closing it flushes the compressed content but
+// doesn't close w.
+func NewWriter(w io.Writer, a Algorithm) (io.WriteCloser, error) {
+	if _, ok := names[a]; !ok {
+		return nil, fmt.Errorf("unknown compression algorithm %d", a)
+	}
+
+	if _, err := w.Write(append([]byte(magic), byte(a))); err != nil {
+		return nil, err
+	}
+
+	return newWriter(w, a)
+}
+
+// Append writes content to w compressed with a but without a header. Gzip
+// members and zstd frames can follow each other, so content appended to
+// compressed content with the same algorithm reads as one.
+func Append(w io.Writer, content io.Reader, a Algorithm) error {
+	cw, err := newWriter(w, a)
+	if err != nil {
+		return err
+	}
+
+	return copyClose(cw, content)
+}
+
+func copyClose(w io.WriteCloser, r io.Reader) error {
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+
+		return err
+	}
+
+	return w.Close()
+}
+
+func newWriter(w io.Writer, a Algorithm) (io.WriteCloser, error) {
+	switch a {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %d", a)
+	}
+}
+
+// NewReader returns a reader of content compressed with a, without its
+// header.
+func NewReader(r io.Reader, a Algorithm) (io.ReadCloser, error) {
+	switch a {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %d", a)
+	}
+}
+
+// Decode returns a reader of the content read from r, whatever it was written
+// with.
+func Decode(r io.Reader) (io.ReadCloser, error) {
+	buf := bufio.NewReader(r)
+
+	head, _ := buf.Peek(HeaderSize)
+
+	a, n, err := ReadHeader(bytes.NewReader(head))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := buf.Discard(int(n)); err != nil {
+		return nil, err
+	}
+
+	return NewReader(buf, a)
+}
+
+// Open returns the content of f decoded. The returned content closes f.
+// Content that isn't compressed is read straight from the file; compressed
+// content is decompressed into memory when it's first read and implements
+// Encoded.
+func Open(f *os.File) (io.ReadSeekCloser, error) {
+	a, n, err := ReadHeader(f)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	if n == 0 {
+		return f, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	section := io.NewSectionReader(f, n, info.Size()-n)
+
+	if a == None {
+		return &plainFile{SectionReader: section, file: f}, nil
+	}
+
+	return &File{file: f, algorithm: a, section: section}, nil
+}
+
+// Size returns the size of the decoded content of the file at path.
+func Size(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	a, n, err := ReadHeader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	if a == None {
+		return info.Size() - n, nil
+	}
+
+	r, err := NewReader(io.NewSectionReader(f, n, info.Size()-n), a)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	return io.Copy(io.Discard, r)
+}
+
+// File is compressed content read from a file.
+type File struct {
+	file      *os.File
+	algorithm Algorithm
+	section   *io.SectionReader
+	decoded   *bytes.Reader
+}
+
+// Encoded returns the content as it's stored if it's compressed with the
+// HTTP content coding encoding, so it can be served without decompressing it.
+func (f *File) Encoded(encoding string) (io.ReadSeeker, bool) {
+	if encoding != f.algorithm.String() {
+		return nil, false
+	}
+
+	return io.NewSectionReader(f.section, 0, f.section.Size()), true
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	if err := f.decode(); err != nil {
+		return 0, err
+	}
+
+	return f.decoded.Read(p)
+}
+
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if err := f.decode(); err != nil {
+		return 0, err
+	}
+
+	return f.decoded.Seek(offset, whence)
+}
+
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+func (f *File) decode() error {
+	if f.decoded != nil {
+		return nil
+	}
+
+	r, err := NewReader(io.NewSectionReader(f.section, 0, f.section.Size()), f.algorithm)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	f.decoded = bytes.NewReader(content)
+
+	return nil
+}
+
+// plainFile is content stored with a header but not compressed.
+type plainFile struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (f *plainFile) Close() error {
+	return f.file.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
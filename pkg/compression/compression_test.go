@@ -0,0 +1,108 @@
+package compression_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/compression"
+	"github.com/kyleterry/jot/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	payload := strings.Repeat("a line of a log file\n", 100)
+
+	for _, a := range []compression.Algorithm{compression.None, compression.Gzip, compression.Zstd} {
+		t.Run(a.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "content")
+
+			var buf bytes.Buffer
+			require.NoError(t, compression.Encode(&buf, strings.NewReader(payload), a))
+			require.NoError(t, compression.Append(&buf, strings.NewReader("appended\n"), a))
+			require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+			if a != compression.None {
+				require.Less(t, buf.Len(), len(payload))
+			}
+
+			size, err := compression.Size(path)
+			require.NoError(t, err)
+			require.EqualValues(t, len(payload)+len("appended\n"), size)
+
+			f, err := os.Open(path)
+			require.NoError(t, err)
+
+			content, err := compression.Open(f)
+			require.NoError(t, err)
+			defer content.Close()
+
+			decoded, err := io.ReadAll(content)
+			require.NoError(t, err)
+			require.Equal(t, payload+"appended\n", string(decoded))
+
+			encoded, ok := content.(types.EncodedContent)
+			require.Equal(t, a != compression.None, ok)
+
+			if ok {
+				_, ok = encoded.Encoded("br")
+				require.False(t, ok)
+
+				raw, ok := encoded.Encoded(a.String())
+				require.True(t, ok)
+
+				stored, err := io.ReadAll(raw)
+				require.NoError(t, err)
+				require.Equal(t, buf.Bytes()[compression.HeaderSize:], stored)
+			}
+
+			r, err := compression.Decode(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+
+			decoded, err = io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, payload+"appended\n", string(decoded))
+		})
+	}
+}
+
+func TestPlainContentLikeAHeader(t *testing.T) {
+	// plain content that happens to start like a header is stored with a
+	// header of its own, so it isn't decompressed when it's read.
+	payload := "\x00jotz\x01 not actually gzip"
+
+	var buf bytes.Buffer
+	require.NoError(t, compression.Encode(&buf, strings.NewReader(payload), compression.None))
+	require.Len(t, buf.Bytes(), compression.HeaderSize+len(payload))
+
+	r, err := compression.Decode(&buf)
+	require.NoError(t, err)
+
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, payload, string(decoded))
+
+	// other plain content is stored as is
+	buf.Reset()
+	require.NoError(t, compression.Encode(&buf, strings.NewReader("plain"), compression.None))
+	require.Equal(t, "plain", buf.String())
+}
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]compression.Algorithm{
+		"":     compression.None,
+		"none": compression.None,
+		"gzip": compression.Gzip,
+		"zstd": compression.Zstd,
+	} {
+		a, err := compression.Parse(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, a)
+	}
+
+	_, err := compression.Parse("lz4")
+	require.Error(t, err)
+}
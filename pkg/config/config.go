@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/google/wire"
 	"github.com/joeshaw/envdecode"
 	"github.com/kyleterry/jot/pkg/auth"
@@ -13,6 +16,7 @@ var ProviderSet = wire.NewSet(
 const (
 	TextDirectoryName    = "txt"
 	ImageDirectoryName   = "img"
+	BlobDirectoryName    = "blobs"
 	FilePermissions      = 0o640
 	DirectoryPermissions = 0o740
 )
@@ -20,12 +24,33 @@ const (
 // TODO: move this to the filesystem backend when img and txt are merged
 type DataDir string
 
+// Compression names the algorithm new content is compressed with in the
+// filesystem backends: none, gzip or zstd.
+type Compression string
+
 type Config struct {
 	SeedFileLocation auth.SeedFileLocation `env:"JOT_SEED_FILE,required"`
 	MasterPassword   auth.MasterPassword   `env:"JOT_MASTER_PASSWORD,required"`
 	DataDir          DataDir               `env:"JOT_DATA_DIR,required"`
 	BindAddr         string                `env:"JOT_BIND_ADDR,default=localhost:8095"`
 	Host             string                `env:"JOT_HOST"`
+	// ReapInterval is how often expired objects are deleted. Zero turns
+	// the reaper off.
+	ReapInterval time.Duration `env:"JOT_REAP_INTERVAL,default=1m"`
+	// AdminPassword guards the /admin routes. They're hidden when it's empty.
+	AdminPassword string `env:"JOT_ADMIN_PASSWORD"`
+	// Encrypt seals the content of every jot and image in DataDir with a key
+	// derived from the master password and seed file. Sealed content isn't
+	// deduplicated or compressed.
+	Encrypt bool `env:"JOT_ENCRYPT,default=false"`
+	// Compression is applied to new jots and galleries. Objects keep the
+	// compression they were written with.
+	Compression Compression `env:"JOT_COMPRESSION,default=none"`
+	// upload limits, in bytes except for MaxImages. Zero means no limit.
+	MaxTextSize    int64 `env:"JOT_MAX_TEXT_SIZE,default=10485760"`
+	MaxImageSize   int64 `env:"JOT_MAX_IMAGE_SIZE,default=10485760"`
+	MaxImages      int   `env:"JOT_MAX_IMAGES,default=20"`
+	MaxRequestSize int64 `env:"JOT_MAX_REQUEST_SIZE,default=52428800"`
 }
 
 func New() (*Config, error) {
@@ -35,5 +60,9 @@ func New() (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.ReapInterval < 0 {
+		return nil, fmt.Errorf("JOT_REAP_INTERVAL can't be negative: %s", cfg.ReapInterval)
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReapInterval(t *testing.T) {
+	t.Setenv("JOT_SEED_FILE", "/tmp/seed")
+	t.Setenv("JOT_MASTER_PASSWORD", "password")
+	t.Setenv("JOT_DATA_DIR", "/tmp/jot")
+
+	cfg, err := config.New()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, cfg.ReapInterval)
+
+	t.Setenv("JOT_REAP_INTERVAL", "0")
+
+	cfg, err = config.New()
+	require.NoError(t, err)
+	require.Zero(t, cfg.ReapInterval)
+
+	t.Setenv("JOT_REAP_INTERVAL", "-1m")
+
+	_, err = config.New()
+	require.ErrorContains(t, err, "JOT_REAP_INTERVAL")
+}
@@ -0,0 +1,415 @@
+// Package diff computes line based differences between two texts and formats
+// them as unified diffs or side by side rows.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is one line of an edit script that turns a into b. A and B are the
+// zero based indexes of the line in a and b. Insert edits have no A and Delete
+// edits have no B; both are -1 in that case.
+type Edit struct {
+	Op   Op
+	Line string
+	A, B int
+}
+
+// SplitLines splits s into lines, keeping the line endings so that a missing
+// newline at the end of the text isn't lost.
+func SplitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// ErrTooDifferent is returned when comparing two texts takes more than the
+// work it's limited to.
+var ErrTooDifferent = errors.New("diff: texts are too different to compare")
+
+// Lines returns the shortest edit script that turns a into b, using the
+// linear space variant of Myers' O(ND) algorithm, so memory only grows with
+// the length of the texts however different they are. Time grows with their
+// length times the number of lines that differ, so it gives up with
+// ErrTooDifferent once it has compared more than limit pairs of lines. A
+// limit of zero or less means no limit.
+func Lines(a, b []string, limit int) ([]Edit, error) {
+	if len(a) == 0 && len(b) == 0 {
+		return nil, nil
+	}
+
+	// the furthest reaching paths of every diagonal, forwards and backwards.
+	// Every middle snake search is done before the next one starts, so the
+	// same slices serve all of them.
+	size := (len(a)+len(b)+1)/2 + 1
+
+	s := &script{
+		a:      a,
+		b:      b,
+		offset: size,
+		vf:     make([]int, 2*size+1),
+		vb:     make([]int, 2*size+1),
+		limit:  limit,
+	}
+
+	if err := s.compare(0, len(a), 0, len(b)); err != nil {
+		return nil, err
+	}
+
+	return s.edits, nil
+}
+
+// script builds the edit script of a and b by splitting them around middle
+// snakes until what's left is only inserts or deletes.
+type script struct {
+	a, b   []string
+	offset int
+	vf, vb []int
+	edits  []Edit
+	// work counts the pairs of lines compared, up to limit.
+	work, limit int
+}
+
+func (s *script) equal(x, y int) {
+	s.edits = append(s.edits, Edit{Op: Equal, Line: s.a[x], A: x, B: y})
+}
+
+// compare appends the edits that turn a[aLo:aHi] into b[bLo:bHi].
+func (s *script) compare(aLo, aHi, bLo, bHi int) error {
+	for aLo < aHi && bLo < bHi && s.a[aLo] == s.b[bLo] {
+		s.equal(aLo, bLo)
+		aLo++
+		bLo++
+	}
+
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && s.a[aHi-suffix-1] == s.b[bHi-suffix-1] {
+		suffix++
+	}
+
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			s.edits = append(s.edits, Edit{Op: Insert, Line: s.b[y], A: -1, B: y})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			s.edits = append(s.edits, Edit{Op: Delete, Line: s.a[x], A: x, B: -1})
+		}
+	default:
+		x, y, u, v, err := s.middleSnake(aLo, aHi, bLo, bHi)
+		if err != nil {
+			return err
+		}
+
+		if err := s.compare(aLo, x, bLo, y); err != nil {
+			return err
+		}
+
+		for ; x < u; x, y = x+1, y+1 {
+			s.equal(x, y)
+		}
+
+		if err := s.compare(u, aHi, v, bHi); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		s.equal(aHi+i, bHi+i)
+	}
+
+	return nil
+}
+
+// step counts n more pairs of lines compared and fails once that's more
+// than the limit.
+func (s *script) step(n int) error {
+	s.work += n
+
+	if s.limit > 0 && s.work > s.limit {
+		return ErrTooDifferent
+	}
+
+	return nil
+}
+
+// middleSnake finds the snake in the middle of a shortest path through the
+// edit graph of a[aLo:aHi] and b[bLo:bHi] by searching from both ends until
+// the paths meet. It returns where the snake starts and ends, (x, y) to
+// (u, v). Both texts must be non-empty and differ in their first and last
+// lines, so the paths on either side of the snake are shorter than the whole.
+func (s *script) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int, err error) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	vf, vb, off := s.vf, s.vb, s.offset
+
+	vf[off+1], vb[off+1] = 0, 0
+
+	for d := 0; d <= (n+m+1)/2; d++ {
+		// every diagonal is at least one comparison, on top of the lines its
+		// snake follows.
+		if err := s.step(2 * (d + 1)); err != nil {
+			return 0, 0, 0, 0, err
+		}
+
+		// forwards, x counts lines of a from aLo.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+
+			y := x - k
+			x0, y0 := x, y
+
+			for x < n && y < m && s.a[aLo+x] == s.b[bLo+y] {
+				x++
+				y++
+			}
+
+			if err := s.step(x - x0); err != nil {
+				return 0, 0, 0, 0, err
+			}
+
+			vf[off+k] = x
+
+			// the backward path on this diagonal has taken d-1 steps.
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && x+vb[off+kb] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y, nil
+			}
+		}
+
+		// backwards, x counts lines of a from aHi.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+
+			y := x - k
+			x0, y0 := x, y
+
+			for x < n && y < m && s.a[aHi-x-1] == s.b[bHi-y-1] {
+				x++
+				y++
+			}
+
+			if err := s.step(x - x0); err != nil {
+				return 0, 0, 0, 0, err
+			}
+
+			vb[off+k] = x
+
+			if kf := delta - k; !odd && kf >= -d && kf <= d && x+vf[off+kf] >= n {
+				return aHi - x, bHi - y, aHi - x0, bHi - y0, nil
+			}
+		}
+	}
+
+	// the paths always meet by the time d reaches half the length of the
+	// texts, so this means a bug.
+	return 0, 0, 0, 0, errors.New("diff: no middle snake")
+}
+
+// Unified returns a unified diff of the texts edits turns into each other,
+// with context lines of unchanged text around every change. fromName and
+// toName are used in the file header. It returns an empty string when the
+// texts are equal.
+func Unified(fromName, toName string, edits []Edit, context int) string {
+	hunks := hunks(edits, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aLen), hunkRange(h.bStart, h.bLen))
+
+		for _, e := range edits[h.start:h.end] {
+			switch e.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+
+			sb.WriteString(e.Line)
+
+			if !strings.HasSuffix(e.Line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+type hunk struct {
+	// start and end are the range of edits in the hunk
+	start, end int
+	// aStart and bStart are the number of lines before the hunk
+	aStart, aLen int
+	bStart, bLen int
+}
+
+// hunks groups the changes in edits, with context lines around them, merging
+// groups whose context would overlap.
+func hunks(edits []Edit, context int) []hunk {
+	var (
+		result  []hunk
+		current *hunk
+	)
+
+	for i, e := range edits {
+		if e.Op == Equal {
+			continue
+		}
+
+		start := max(i-context, 0)
+		if current != nil && start <= current.end {
+			current.end = min(i+context+1, len(edits))
+
+			continue
+		}
+
+		if current != nil {
+			result = append(result, *current)
+		}
+
+		current = &hunk{start: start, end: min(i+context+1, len(edits))}
+	}
+
+	if current != nil {
+		result = append(result, *current)
+	}
+
+	for i := range result {
+		h := &result[i]
+
+		h.aStart, h.bStart = linesBefore(edits, h.start)
+
+		for _, e := range edits[h.start:h.end] {
+			if e.Op != Insert {
+				h.aLen++
+			}
+
+			if e.Op != Delete {
+				h.bLen++
+			}
+		}
+	}
+
+	return result
+}
+
+// linesBefore returns how many lines of a and b come before edits[i].
+func linesBefore(edits []Edit, i int) (int, int) {
+	var a, b int
+
+	for _, e := range edits[:i] {
+		if e.Op != Insert {
+			a++
+		}
+
+		if e.Op != Delete {
+			b++
+		}
+	}
+
+	return a, b
+}
+
+func hunkRange(before, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, length)
+	}
+}
+
+// Cell is one side of a Row. A Number of 0 means the side is empty.
+type Cell struct {
+	Number int
+	Text   string
+	Op     Op
+}
+
+// Row is a line of a side by side view of two texts.
+type Row struct {
+	Left, Right Cell
+}
+
+// SideBySide lays edits out as rows with the old text on the left and the new
+// text on the right. Runs of deleted lines followed by inserted lines are
+// paired up so changed lines sit next to each other.
+func SideBySide(edits []Edit) []Row {
+	var rows []Row
+
+	for i := 0; i < len(edits); {
+		e := edits[i]
+
+		if e.Op == Equal {
+			rows = append(rows, Row{
+				Left:  Cell{Number: e.A + 1, Text: e.Line, Op: Equal},
+				Right: Cell{Number: e.B + 1, Text: e.Line, Op: Equal},
+			})
+			i++
+
+			continue
+		}
+
+		var deleted, inserted []Edit
+		for ; i < len(edits) && edits[i].Op == Delete; i++ {
+			deleted = append(deleted, edits[i])
+		}
+		for ; i < len(edits) && edits[i].Op == Insert; i++ {
+			inserted = append(inserted, edits[i])
+		}
+
+		for j := range max(len(deleted), len(inserted)) {
+			var row Row
+
+			if j < len(deleted) {
+				row.Left = Cell{Number: deleted[j].A + 1, Text: deleted[j].Line, Op: Delete}
+			}
+
+			if j < len(inserted) {
+				row.Right = Cell{Number: inserted[j].B + 1, Text: inserted[j].Line, Op: Insert}
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
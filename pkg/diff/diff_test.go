@@ -0,0 +1,194 @@
+package diff_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/diff"
+	"github.com/stretchr/testify/require"
+)
+
+// apply rebuilds both sides of an edit script so it can be checked against
+// the inputs.
+func apply(edits []diff.Edit) (string, string) {
+	var a, b strings.Builder
+
+	for _, e := range edits {
+		if e.Op != diff.Insert {
+			a.WriteString(e.Line)
+		}
+
+		if e.Op != diff.Delete {
+			b.WriteString(e.Line)
+		}
+	}
+
+	return a.String(), b.String()
+}
+
+func TestLines(t *testing.T) {
+	cases := []struct {
+		a, b    string
+		changes int
+	}{
+		{"", "", 0},
+		{"a\n", "a\n", 0},
+		{"", "a\nb\n", 2},
+		{"a\nb\n", "", 2},
+		{"a\nb\nc\n", "a\nc\n", 1},
+		{"a\nb\nc\n", "a\nB\nc\n", 2},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+		{"a", "a\n", 2},
+	}
+
+	for _, c := range cases {
+		edits, err := diff.Lines(diff.SplitLines(c.a), diff.SplitLines(c.b), 0)
+		require.NoError(t, err)
+
+		a, b := apply(edits)
+		require.Equal(t, c.a, a)
+		require.Equal(t, c.b, b)
+
+		var changes int
+		for _, e := range edits {
+			if e.Op != diff.Equal {
+				changes++
+			}
+		}
+
+		require.Equal(t, c.changes, changes, "%q -> %q", c.a, c.b)
+	}
+}
+
+// TestLinesShortest checks random texts against the length of their longest
+// common subsequence, which every shortest edit script keeps.
+func TestLinesShortest(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	random := func() []string {
+		lines := make([]string, rng.IntN(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.IntN(4))) + "\n"
+		}
+
+		return lines
+	}
+
+	for range 500 {
+		a, b := random(), random()
+
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		edits, err := diff.Lines(a, b, 0)
+		require.NoError(t, err)
+
+		gotA, gotB := apply(edits)
+		require.Equal(t, strings.Join(a, ""), gotA)
+		require.Equal(t, strings.Join(b, ""), gotB)
+
+		var changes int
+		for _, e := range edits {
+			if e.Op != diff.Equal {
+				changes++
+			}
+		}
+
+		require.Equal(t, len(a)+len(b)-2*lcs[0][0], changes, "%q -> %q", a, b)
+	}
+}
+
+// TestLinesMemory diffs texts with nothing in common, the worst case, and
+// checks memory stays linear in their length.
+func TestLinesMemory(t *testing.T) {
+	a, b := make([]string, 3000), make([]string, 3000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	edits, err := diff.Lines(a, b, 0)
+
+	runtime.ReadMemStats(&after)
+
+	require.NoError(t, err)
+	require.Len(t, edits, 6000)
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(10<<20))
+}
+
+// TestLinesLimit checks texts that take too much work to compare are given
+// up on, however similar their length.
+func TestLinesLimit(t *testing.T) {
+	a, b := make([]string, 3000), make([]string, 3000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+
+	_, err := diff.Lines(a, b, 1_000_000)
+	require.ErrorIs(t, err, diff.ErrTooDifferent)
+
+	// texts with few differences stay well under it.
+	b = append(a[:1500:1500], append([]string{"changed\n"}, a[1501:]...)...)
+
+	edits, err := diff.Lines(a, b, 1_000_000)
+	require.NoError(t, err)
+	require.Len(t, edits, 3001)
+}
+
+func TestUnified(t *testing.T) {
+	a := diff.SplitLines("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	b := diff.SplitLines("one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven")
+
+	expected := `--- a
++++ b
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+@@ -10 +10,2 @@
+ ten
++eleven
+\ No newline at end of file
+`
+
+	edits, err := diff.Lines(a, b, 0)
+	require.NoError(t, err)
+	require.Equal(t, expected, diff.Unified("a", "b", edits, 1))
+
+	edits, err = diff.Lines(a, a, 0)
+	require.NoError(t, err)
+	require.Empty(t, diff.Unified("a", "b", edits, 3))
+}
+
+func TestSideBySide(t *testing.T) {
+	edits, err := diff.Lines(diff.SplitLines("a\nb\nc\n"), diff.SplitLines("a\nB\nc\nd\n"), 0)
+	require.NoError(t, err)
+	rows := diff.SideBySide(edits)
+
+	require.Len(t, rows, 4)
+	require.Equal(t, diff.Cell{Number: 2, Text: "b\n", Op: diff.Delete}, rows[1].Left)
+	require.Equal(t, diff.Cell{Number: 2, Text: "B\n", Op: diff.Insert}, rows[1].Right)
+	require.Zero(t, rows[3].Left.Number)
+	require.Equal(t, 4, rows[3].Right.Number)
+}
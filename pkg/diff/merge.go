@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+// Merge combines the changes a and b each made to base, line by line. Changes
+// to different parts of base are both kept. Where a and b changed the same
+// lines differently, both versions are kept between conflict markers labelled
+// aName and bName, and ok is false. limit bounds comparing base to a and b
+// like it does for Lines.
+func Merge(base, a, b []string, aName, bName string, limit int) (merged []string, ok bool, err error) {
+	matchA, err := matches(base, a, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	matchB, err := matches(base, b, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok = true
+
+	var i, j, k int
+
+	for {
+		// find the next base line both a and b kept, so the lines before it
+		// can be merged on their own.
+		next := i
+		for next < len(base) && (matchA[next] < 0 || matchB[next] < 0) {
+			next++
+		}
+
+		endA, endB := len(a), len(b)
+		if next < len(base) {
+			endA, endB = matchA[next], matchB[next]
+		}
+
+		chunkBase, chunkA, chunkB := base[i:next], a[j:endA], b[k:endB]
+
+		switch {
+		case slices.Equal(chunkA, chunkBase):
+			merged = append(merged, chunkB...)
+		case slices.Equal(chunkB, chunkBase), slices.Equal(chunkA, chunkB):
+			merged = append(merged, chunkA...)
+		default:
+			ok = false
+
+			merged = append(merged, "<<<<<<< "+aName+"\n")
+			merged = append(merged, terminated(chunkA)...)
+			merged = append(merged, "=======\n")
+			merged = append(merged, terminated(chunkB)...)
+			merged = append(merged, ">>>>>>> "+bName+"\n")
+		}
+
+		if next == len(base) {
+			return merged, ok, nil
+		}
+
+		merged = append(merged, base[next])
+		i, j, k = next+1, endA+1, endB+1
+	}
+}
+
+// matches returns, for every line of base, the index of the line of other it
+// was kept as, or -1 when it was deleted or changed.
+func matches(base, other []string, limit int) ([]int, error) {
+	edits, err := Lines(base, other, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+
+	for _, e := range edits {
+		if e.Op == Equal {
+			match[e.A] = e.B
+		}
+	}
+
+	return match, nil
+}
+
+// terminated returns lines with a newline at the end of the last one, so
+// conflict markers after it start on their own line.
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+
+	lines = slices.Clone(lines)
+	lines[len(lines)-1] += "\n"
+
+	return lines
+}
@@ -0,0 +1,43 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/diff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		name       string
+		base, a, b string
+		merged     string
+		ok         bool
+	}{
+		{"unchanged", "a\nb\n", "a\nb\n", "a\nb\n", "a\nb\n", true},
+		{"only a", "a\nb\n", "a\nB\n", "a\nb\n", "a\nB\n", true},
+		{"only b", "a\nb\n", "a\nb\n", "A\nb\n", "A\nb\n", true},
+		{"different lines", "a\nb\nc\n", "A\nb\nc\n", "a\nb\nC\n", "A\nb\nC\n", true},
+		{"same change", "a\nb\n", "a\nB\n", "a\nB\n", "a\nB\n", true},
+		{"insert and delete", "a\nb\nc\n", "a\nb\nc\nd\n", "b\nc\n", "b\nc\nd\n", true},
+		{"empty base", "", "a\n", "", "a\n", true},
+		{
+			"conflict", "a\nb\nc\n", "a\nB1\nc\n", "a\nB2\nc\n",
+			"a\n<<<<<<< current\nB1\n=======\nB2\n>>>>>>> yours\nc\n", false,
+		},
+		{
+			"conflicting inserts", "a\n", "a\nx", "a\ny\n",
+			"a\n<<<<<<< current\nx\n=======\ny\n>>>>>>> yours\n", false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			merged, ok, err := diff.Merge(diff.SplitLines(c.base), diff.SplitLines(c.a), diff.SplitLines(c.b), "current", "yours", 0)
+			require.NoError(t, err)
+			require.Equal(t, c.ok, ok)
+			require.Equal(t, c.merged, strings.Join(merged, ""))
+		})
+	}
+}
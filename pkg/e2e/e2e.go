@@ -0,0 +1,152 @@
+// Package e2e encrypts jots on the client so the server never sees their
+// content. It uses the same format as the private jot page in the browser, so
+// jots created with either can be read with the other.
+//
+// Content is sealed with AES-256-GCM under a random key. The server stores a
+// JSON blob holding the nonce and ciphertext, and the key is only ever put in
+// the fragment of the jot URL, which browsers don't send to the server:
+//
+//	{"v":1,"iv":"<nonce>","ct":"<ciphertext>"}
+//
+// The key, nonce and ciphertext are all unpadded URL safe base64.
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Version is the version of the blob format written by Encrypt.
+const Version = 1
+
+const keySize = 32
+
+var encoding = base64.RawURLEncoding
+
+// Blob is what the server stores for an encrypted jot.
+type Blob struct {
+	Version    int    `json:"v"`
+	Nonce      string `json:"iv"`
+	Ciphertext string `json:"ct"`
+}
+
+// Encrypt seals content under a new random key. It returns the blob to upload
+// and the key to put in the URL fragment.
+func Encrypt(content []byte) ([]byte, string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+
+	blob, err := json.Marshal(Blob{
+		Version:    Version,
+		Nonce:      encoding.EncodeToString(nonce),
+		Ciphertext: encoding.EncodeToString(aead.Seal(nil, nonce, content, nil)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return blob, encoding.EncodeToString(key), nil
+}
+
+// Decrypt opens a blob created by Encrypt or the private jot page with key.
+func Decrypt(blob []byte, key string) ([]byte, error) {
+	var b Blob
+	if err := json.Unmarshal(blob, &b); err != nil {
+		return nil, fmt.Errorf("invalid encrypted jot: %w", err)
+	}
+
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported encrypted jot version: %d", b.Version)
+	}
+
+	rawKey, err := encoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	if len(rawKey) != keySize {
+		return nil, fmt.Errorf("invalid key: want %d bytes, got %d", keySize, len(rawKey))
+	}
+
+	nonce, err := encoding.DecodeString(b.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+
+	ciphertext, err := encoding.DecodeString(b.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	aead, err := newAEAD(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce: want %d bytes, got %d", aead.NonceSize(), len(nonce))
+	}
+
+	content, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt jot, the key is probably wrong: %w", err)
+	}
+
+	return content, nil
+}
+
+// JoinURL returns the URL of an encrypted jot with key in its fragment.
+func JoinURL(jotURL, key string) (string, error) {
+	u, err := url.Parse(jotURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Fragment = key
+
+	return u.String(), nil
+}
+
+// SplitURL splits the URL of an encrypted jot into the URL to fetch it from
+// and the key in its fragment.
+func SplitURL(jotURL string) (string, string, error) {
+	u, err := url.Parse(jotURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := u.Fragment
+	if key == "" {
+		return "", "", fmt.Errorf("no key in the fragment of %s", jotURL)
+	}
+
+	u.Fragment = ""
+
+	return u.String(), key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,38 @@
+package e2e_test
+
+import (
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/e2e"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	blob, key, err := e2e.Encrypt([]byte("top secret"))
+	require.NoError(t, err)
+	require.NotContains(t, string(blob), "top secret")
+
+	content, err := e2e.Decrypt(blob, key)
+	require.NoError(t, err)
+	require.Equal(t, "top secret", string(content))
+
+	_, otherKey, err := e2e.Encrypt(nil)
+	require.NoError(t, err)
+
+	_, err = e2e.Decrypt(blob, otherKey)
+	require.Error(t, err)
+}
+
+func TestURL(t *testing.T) {
+	u, err := e2e.JoinURL("https://jot.example/txt/abc", "key")
+	require.NoError(t, err)
+	require.Equal(t, "https://jot.example/txt/abc#key", u)
+
+	jotURL, key, err := e2e.SplitURL(u)
+	require.NoError(t, err)
+	require.Equal(t, "https://jot.example/txt/abc", jotURL)
+	require.Equal(t, "key", key)
+
+	_, _, err = e2e.SplitURL("https://jot.example/txt/abc")
+	require.Error(t, err)
+}
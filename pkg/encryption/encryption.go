@@ -0,0 +1,294 @@
+// Package encryption seals stored objects with AES-GCM so that reading the
+// data dir doesn't give away their content. TextBackend and ImageBackend wrap
+// the storage backends of jots and galleries.
+//
+// Sealed content starts with a short header followed by records, each of
+// which is a big endian uint32 length and a nonce followed by the sealed
+// bytes. Content is split into records so appending to a jot only has to seal
+// the new content.
+//
+// Every record is sealed with the offset of its plaintext in the content, and
+// the last record of what was written at once is marked final, so records
+// can't be dropped, reordered or cut off without opening failing. The final
+// mark is kept in the top bit of the length. Cutting content off where an
+// append started gives back the content as it was before, like restoring an
+// older copy of the data dir would.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/wire"
+	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+var ProviderSet = wire.NewSet(
+	ProvideCipher,
+)
+
+// header marks sealed content. It isn't valid UTF-8 text, so plaintext jots
+// are never mistaken for sealed ones.
+var header = []byte("\x00jotenc\x02")
+
+// recordSize is the most plaintext sealed into one record.
+const recordSize = 64 << 10
+
+// maxRecordLength guards against allocating huge buffers for a corrupt length.
+const maxRecordLength = recordSize + 1<<10
+
+// finalRecord is set in the length of the last record written at once.
+const finalRecord = 1 << 31
+
+// Cipher seals and opens stored content.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// Sealed reports whether content starts with the header of sealed content.
+func Sealed(content []byte) bool {
+	return bytes.HasPrefix(content, header)
+}
+
+// Seal returns content sealed under the additional data ad, which has to be
+// given again to open it.
+func (c *Cipher) Seal(content, ad []byte) []byte {
+	var buf bytes.Buffer
+
+	buf.Write(header)
+
+	for offset := 0; ; {
+		n := min(len(content)-offset, recordSize)
+		final := offset+n == len(content)
+
+		c.writeRecord(&buf, content[offset:offset+n], ad, int64(offset), final)
+
+		if final {
+			return buf.Bytes()
+		}
+
+		offset += n
+	}
+}
+
+// Open returns the plaintext of sealed content read from r.
+func (c *Cipher) Open(r io.Reader, ad []byte) ([]byte, error) {
+	head := make([]byte, len(header))
+	if _, err := io.ReadFull(r, head); err != nil || !Sealed(head) {
+		return nil, errors.NewUnknownError("content is not encrypted")
+	}
+
+	var plaintext []byte
+
+	// content ends with a final record, which content that was cut short is
+	// missing.
+	var complete bool
+
+	err := readRecords(r, func(sealed []byte, final bool) error {
+		recordAD := recordAdditionalData(ad, int64(len(plaintext)), final)
+
+		nonceSize := c.aead.NonceSize()
+		if len(sealed) < nonceSize {
+			return errors.NewUnknownError("sealed record is too short")
+		}
+
+		opened, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], recordAD)
+		if err != nil {
+			return errors.NewUnknownError("failed to open sealed content").WithCause(err)
+		}
+
+		plaintext = append(plaintext, opened...)
+		complete = final
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !complete {
+		return nil, errors.NewUnknownError("sealed content is truncated")
+	}
+
+	return plaintext, nil
+}
+
+// recordAdditionalData returns what a record is sealed under: ad, the offset
+// of the record's plaintext and whether it's final.
+func recordAdditionalData(ad []byte, offset int64, final bool) []byte {
+	recordAD := make([]byte, len(ad), len(ad)+9)
+	copy(recordAD, ad)
+
+	recordAD = binary.BigEndian.AppendUint64(recordAD, uint64(offset))
+
+	if final {
+		return append(recordAD, 1)
+	}
+
+	return append(recordAD, 0)
+}
+
+// readRecords calls fn with every record read from r, which is past the
+// header of sealed content, and whether it's final.
+func readRecords(r io.Reader, fn func(sealed []byte, final bool) error) error {
+	var length [4]byte
+
+	for {
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return errors.NewUnknownError("sealed content is truncated").WithCause(err)
+		}
+
+		n := binary.BigEndian.Uint32(length[:])
+		final := n&finalRecord != 0
+		n &^= finalRecord
+
+		if n > maxRecordLength {
+			return errors.NewUnknownError(fmt.Sprintf("sealed record is too long: %d bytes", n))
+		}
+
+		sealed := make([]byte, n)
+		if _, err := io.ReadFull(r, sealed); err != nil {
+			return errors.NewUnknownError("sealed content is truncated").WithCause(err)
+		}
+
+		if err := fn(sealed, final); err != nil {
+			return err
+		}
+	}
+}
+
+// writeRecord seals content as the record at offset into w.
+func (c *Cipher) writeRecord(w *bytes.Buffer, content, ad []byte, offset int64, final bool) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, content, recordAdditionalData(ad, offset, final))
+
+	length := uint32(len(sealed))
+	if final {
+		length |= finalRecord
+	}
+
+	binary.Write(w, binary.BigEndian, length)
+	w.Write(sealed)
+}
+
+// sealReader seals content from src as it's read. Only the first reader of a
+// piece of content writes the header, so appended records can be sealed on
+// their own, starting at the offset where the content they're appended to
+// ends.
+type sealReader struct {
+	cipher *Cipher
+	src    *bufio.Reader
+	closer io.Closer
+	ad     []byte
+	offset int64
+	buf    bytes.Buffer
+	chunk  []byte
+	err    error
+	// size counts the plaintext sealed so far.
+	size int64
+}
+
+func (c *Cipher) sealReader(src io.ReadCloser, ad []byte, offset int64, withHeader bool) *sealReader {
+	sr := &sealReader{
+		cipher: c,
+		src:    bufio.NewReaderSize(src, recordSize),
+		closer: src,
+		ad:     ad,
+		offset: offset,
+		chunk:  make([]byte, recordSize),
+	}
+
+	if withHeader {
+		sr.buf.Write(header)
+	}
+
+	return sr
+}
+
+func (sr *sealReader) Read(p []byte) (int, error) {
+	for sr.buf.Len() == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+
+		n, err := io.ReadFull(sr.src, sr.chunk)
+		if err == nil {
+			// a full record is only final if nothing follows it.
+			_, err = sr.src.Peek(1)
+		}
+
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			sr.err = io.EOF
+		default:
+			sr.err = err
+
+			continue
+		}
+
+		sr.cipher.writeRecord(&sr.buf, sr.chunk[:n], sr.ad, sr.offset+sr.size, sr.err == io.EOF)
+		sr.size += int64(n)
+	}
+
+	return sr.buf.Read(p)
+}
+
+// Size returns the size of the plaintext sealed, so backends record it
+// instead of the size of the sealed content.
+func (sr *sealReader) Size() int64 {
+	return sr.size
+}
+
+func (sr *sealReader) Close() error {
+	return sr.closer.Close()
+}
+
+// plaintext is opened content. It can seek so it can be served in ranges.
+type plaintext struct {
+	*bytes.Reader
+}
+
+func (plaintext) Close() error {
+	return nil
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// ProvideCipher returns a Cipher keyed from the master password and seed.
+func ProvideCipher(sf *auth.SeedFile) (*Cipher, error) {
+	key, err := sf.EncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCipher(key)
+}
@@ -0,0 +1,293 @@
+package encryption_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/encryption"
+	"github.com/kyleterry/jot/pkg/image/backend"
+	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
+	"github.com/kyleterry/jot/pkg/testutil"
+	"github.com/kyleterry/jot/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newCipher(t *testing.T) *encryption.Cipher {
+	t.Helper()
+
+	c, err := encryption.NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestTextBackend(t *testing.T) {
+	tmpdir, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	b := encryption.NewTextBackend(fs, newCipher(t))
+
+	key := "abc123"
+
+	require.NoError(t, b.Put(key, io.NopCloser(strings.NewReader("first secret"))))
+	require.NoError(t, b.Put(key, io.NopCloser(strings.NewReader("second secret"))))
+	require.NoError(t, b.Append(key, io.NopCloser(strings.NewReader(" and more"))))
+
+	resp, err := fs.Get(key)
+	require.NoError(t, err)
+
+	raw, err := io.ReadAll(resp.Content)
+	resp.Content.Close()
+	require.NoError(t, err)
+	require.True(t, encryption.Sealed(raw))
+
+	requireNoPlaintext(t, tmpdir, "secret")
+
+	resp, err = b.Get(key)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(resp.Content)
+	require.NoError(t, err)
+	require.Equal(t, "second secret and more", string(content))
+
+	// content is served in ranges, so it has to seek
+	_, ok := resp.Content.(io.Seeker)
+	require.True(t, ok)
+
+	revisions, err := b.Revisions(key)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.EqualValues(t, len("first secret"), revisions[0].Size)
+	require.EqualValues(t, len("second secret and more"), revisions[1].Size)
+
+	resp, err = b.GetRevision(key, 1)
+	require.NoError(t, err)
+
+	content, err = io.ReadAll(resp.Content)
+	require.NoError(t, err)
+	require.Equal(t, "first secret", string(content))
+
+	// sealed content is bound to its key
+	require.NoError(t, os.Rename(filepath.Join(tmpdir, key), filepath.Join(tmpdir, "other")))
+
+	_, err = b.Get("other")
+	require.Error(t, err)
+}
+
+func TestSealedRecords(t *testing.T) {
+	c := newCipher(t)
+	ad := []byte("abc123")
+
+	// three records, the last one short
+	content := bytes.Repeat([]byte("secret "), 20000)
+	sealed := c.Seal(content, ad)
+
+	opened, err := c.Open(bytes.NewReader(sealed), ad)
+	require.NoError(t, err)
+	require.Equal(t, content, opened)
+
+	head, records := splitRecords(t, sealed)
+	require.Len(t, records, 3)
+
+	for name, records := range map[string][][]byte{
+		"truncated": records[:2],
+		"dropped":   {records[0], records[2]},
+		"reordered": {records[1], records[0], records[2]},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.Open(bytes.NewReader(joinRecords(head, records)), ad)
+			require.Error(t, err)
+		})
+	}
+
+	// empty content is sealed as a final record too, so it can't be mistaken
+	// for content with its records cut off.
+	empty := c.Seal(nil, ad)
+
+	opened, err = c.Open(bytes.NewReader(empty), ad)
+	require.NoError(t, err)
+	require.Empty(t, opened)
+
+	_, err = c.Open(bytes.NewReader(head), ad)
+	require.Error(t, err)
+}
+
+func TestTextBackendAppend(t *testing.T) {
+	_, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	b := encryption.NewTextBackend(fs, newCipher(t))
+
+	key := "abc123"
+	long := strings.Repeat("x", 70000)
+
+	require.NoError(t, b.Put(key, io.NopCloser(strings.NewReader("first"))))
+	require.NoError(t, b.Append(key, io.NopCloser(strings.NewReader(long))))
+	require.NoError(t, b.Append(key, io.NopCloser(strings.NewReader("last"))))
+
+	resp, err := b.Get(key)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(resp.Content)
+	require.NoError(t, err)
+	require.Equal(t, "first"+long+"last", string(content))
+
+	// what's appended is checked like the rest, so it can't be cut short.
+	resp, err = fs.Get(key)
+	require.NoError(t, err)
+
+	raw, err := io.ReadAll(resp.Content)
+	resp.Content.Close()
+	require.NoError(t, err)
+
+	head, records := splitRecords(t, raw)
+	require.Len(t, records, 4)
+
+	require.NoError(t, fs.Put(key, io.NopCloser(bytes.NewReader(joinRecords(head, records[:2])))))
+
+	_, err = b.Get(key)
+	require.Error(t, err)
+}
+
+// splitRecords splits sealed content into its header and records. The records
+// keep their length, with the final mark.
+func splitRecords(t *testing.T, sealed []byte) ([]byte, [][]byte) {
+	t.Helper()
+
+	head, rest := sealed[:8], sealed[8:]
+
+	var records [][]byte
+
+	for len(rest) > 0 {
+		require.GreaterOrEqual(t, len(rest), 4)
+
+		n := int(binary.BigEndian.Uint32(rest) &^ (1 << 31))
+		records = append(records, rest[:4+n])
+		rest = rest[4+n:]
+	}
+
+	return head, records
+}
+
+// joinRecords returns sealed content made of head and records.
+func joinRecords(head []byte, records [][]byte) []byte {
+	content := bytes.Clone(head)
+
+	for _, record := range records {
+		content = append(content, record...)
+	}
+
+	return content
+}
+
+func TestMigrateText(t *testing.T) {
+	_, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	c := newCipher(t)
+	key := "abc123"
+
+	require.NoError(t, fs.Put(key, io.NopCloser(strings.NewReader("old"))))
+	require.NoError(t, fs.Put(key, io.NopCloser(strings.NewReader("new"))))
+
+	before, err := fs.Revisions(key)
+	require.NoError(t, err)
+
+	sealed, err := c.MigrateText(fs)
+	require.NoError(t, err)
+	require.Equal(t, 2, sealed)
+
+	// running it again leaves sealed content alone
+	sealed, err = c.MigrateText(fs)
+	require.NoError(t, err)
+	require.Zero(t, sealed)
+
+	b := encryption.NewTextBackend(fs, c)
+
+	after, err := b.Revisions(key)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	resp, err := b.Get(key)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(resp.Content)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(content))
+}
+
+func TestImageBackend(t *testing.T) {
+	tmp := t.TempDir()
+
+	blobs, err := blob.New(&blob.Options{StorageDir: config.DataDir(tmp)})
+	require.NoError(t, err)
+
+	fs, err := imagefs.New(&imagefs.Options{StorageDir: config.DataDir(tmp), Blobs: blobs})
+	require.NoError(t, err)
+
+	c := newCipher(t)
+	ctx := context.Background()
+
+	images := &types.Images{}
+	images.Add("plain.png", &types.ImageData{
+		Name:        "plain.png",
+		Content:     io.NopCloser(strings.NewReader("plain image")),
+		ContentType: "image/png",
+	})
+
+	require.NoError(t, fs.Create(ctx, "plain", images, backend.Metadata{}))
+
+	sealed, err := c.MigrateImages(ctx, fs)
+	require.NoError(t, err)
+	require.Equal(t, 1, sealed)
+
+	b := encryption.NewImageBackend(fs, c)
+
+	images = &types.Images{}
+	images.Add("my image.png", &types.ImageData{
+		Name:        "my image.png",
+		Content:     io.NopCloser(strings.NewReader("secret image")),
+		ContentType: "image/png",
+	})
+
+	require.NoError(t, b.Create(ctx, "secret", images, backend.Metadata{}))
+
+	for id, expected := range map[string]string{"plain": "plain image", "secret": "secret image"} {
+		images, err := b.Get(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, images.Keys, 1)
+
+		content, err := io.ReadAll(images.Values[images.Keys[0]].Content)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(content))
+	}
+
+	requireNoPlaintext(t, tmp, "image")
+}
+
+// requireNoPlaintext checks that no blob stored under dir contains plaintext.
+func requireNoPlaintext(t *testing.T, dir, plaintext string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, config.BlobDirectoryName, "*", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".refs") {
+			continue
+		}
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(raw), plaintext)
+	}
+}
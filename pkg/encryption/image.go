@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/kyleterry/jot/pkg/image/backend"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+// ImageBackend seals the images of galleries before they reach the wrapped
+// backend and opens them again on the way out. Every image is sealed under
+// its gallery and name. Names, content types and metadata are stored as is.
+type ImageBackend struct {
+	backend backend.Interface
+	cipher  *Cipher
+}
+
+func (b *ImageBackend) Stat(ctx context.Context, key string) (*backend.StatResponse, error) {
+	return b.backend.Stat(ctx, key)
+}
+
+func (b *ImageBackend) Get(ctx context.Context, key string) (*types.Images, error) {
+	images, err := b.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range images.Keys {
+		image := images.Values[name]
+
+		content, err := b.cipher.Open(image.Content, imageAdditionalData(key, name))
+		image.Content.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		image.Content = io.NopCloser(bytes.NewReader(content))
+	}
+
+	return images, nil
+}
+
+func (b *ImageBackend) Create(ctx context.Context, key string, images *types.Images, meta backend.Metadata) error {
+	for _, name := range images.Keys {
+		image := images.Values[name]
+		image.Content = b.cipher.sealReader(image.Content, imageAdditionalData(key, name), 0, true)
+	}
+
+	return b.backend.Create(ctx, key, images, meta)
+}
+
+func (b *ImageBackend) PutMetadata(ctx context.Context, key string, meta backend.Metadata) error {
+	return b.backend.PutMetadata(ctx, key, meta)
+}
+
+func (b *ImageBackend) Delete(ctx context.Context, key string) error {
+	return b.backend.Delete(ctx, key)
+}
+
+func (b *ImageBackend) List(ctx context.Context) ([]string, error) {
+	return b.backend.List(ctx)
+}
+
+// imageAdditionalData is the additional data an image named name in the
+// gallery key is sealed under.
+func imageAdditionalData(key, name string) []byte {
+	return []byte(key + "/" + name)
+}
+
+func NewImageBackend(backend backend.Interface, cipher *Cipher) *ImageBackend {
+	return &ImageBackend{
+		backend: backend,
+		cipher:  cipher,
+	}
+}
@@ -0,0 +1,54 @@
+package encryption
+
+import (
+	"context"
+)
+
+// TextRewriter is a jot backend that can rewrite everything it stores in
+// place.
+type TextRewriter interface {
+	Rewrite(fn func(key string, content []byte) ([]byte, error)) error
+}
+
+// ImageRewriter is a gallery backend that can rewrite every image it stores
+// in place.
+type ImageRewriter interface {
+	Rewrite(ctx context.Context, fn func(id, name string, content []byte) ([]byte, error)) error
+}
+
+// MigrateText seals every jot and revision in r that's still plaintext and
+// returns how many it sealed. Content that's already sealed is left alone, so
+// an interrupted migration can be run again.
+func (c *Cipher) MigrateText(r TextRewriter) (int, error) {
+	var sealed int
+
+	err := r.Rewrite(func(key string, content []byte) ([]byte, error) {
+		if Sealed(content) {
+			return content, nil
+		}
+
+		sealed++
+
+		return c.Seal(content, []byte(key)), nil
+	})
+
+	return sealed, err
+}
+
+// MigrateImages seals every image in r that's still plaintext and returns how
+// many it sealed. Images that are already sealed are left alone.
+func (c *Cipher) MigrateImages(ctx context.Context, r ImageRewriter) (int, error) {
+	var sealed int
+
+	err := r.Rewrite(ctx, func(id, name string, content []byte) ([]byte, error) {
+		if Sealed(content) {
+			return content, nil
+		}
+
+		sealed++
+
+		return c.Seal(content, imageAdditionalData(id, name)), nil
+	})
+
+	return sealed, err
+}
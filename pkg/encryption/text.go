@@ -0,0 +1,173 @@
+package encryption
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/kyleterry/jot/pkg/jot/store"
+)
+
+var _ store.Appender = (*TextBackend)(nil)
+
+// TextBackend seals the content of jots before it reaches the wrapped backend
+// and opens it again on the way out. Content is sealed under the key of its
+// jot, so it can't be moved to another key. Metadata is stored as is.
+type TextBackend struct {
+	backend store.Backend
+	cipher  *Cipher
+
+	// writing holds a lock for every jot being written, since appended
+	// records are sealed with the offset where the jot ends.
+	mu      sync.Mutex
+	writing map[string]*keyLock
+}
+
+// keyLock is held while a jot is written. users counts the writers holding or
+// waiting for it.
+type keyLock struct {
+	sync.Mutex
+	users int
+}
+
+func (b *TextBackend) Stat(key string) (*store.StatResponse, error) {
+	return b.backend.Stat(key)
+}
+
+func (b *TextBackend) Get(key string) (*store.GetResponse, error) {
+	return b.open(key, b.backend.Get)
+}
+
+func (b *TextBackend) Take(key string) (*store.GetResponse, error) {
+	return b.open(key, b.backend.Take)
+}
+
+func (b *TextBackend) Put(key string, content io.ReadCloser) error {
+	defer b.lock(key)()
+
+	return b.put(key, content)
+}
+
+func (b *TextBackend) put(key string, content io.ReadCloser) error {
+	return b.backend.Put(key, b.cipher.sealReader(content, []byte(key), 0, true))
+}
+
+// Append seals content on its own and appends it to the sealed jot. Backends
+// that can't append, and jots whose size isn't known, get the whole jot sealed
+// again.
+func (b *TextBackend) Append(key string, content io.ReadCloser) error {
+	defer b.lock(key)()
+
+	if appender, ok := b.backend.(store.Appender); ok {
+		offset, err := b.appendOffset(key)
+		if err != nil {
+			content.Close()
+
+			return err
+		}
+
+		if offset >= 0 {
+			return appender.Append(key, b.cipher.sealReader(content, []byte(key), offset, false))
+		}
+	}
+
+	defer content.Close()
+
+	resp, err := b.Get(key)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Content.Close()
+
+	return b.put(key, io.NopCloser(io.MultiReader(resp.Content, content)))
+}
+
+// appendOffset returns the size of the plaintext of the jot, where appended
+// records start, or -1 if they can't be appended to it.
+func (b *TextBackend) appendOffset(key string) (int64, error) {
+	revisions, err := b.backend.Revisions(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return revisions[len(revisions)-1].Size, nil
+}
+
+// lock locks the jot under key for writing and returns the function that
+// unlocks it.
+func (b *TextBackend) lock(key string) func() {
+	b.mu.Lock()
+
+	l, ok := b.writing[key]
+	if !ok {
+		l = &keyLock{}
+		b.writing[key] = l
+	}
+
+	l.users++
+	b.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		l.users--
+		if l.users == 0 {
+			delete(b.writing, key)
+		}
+	}
+}
+
+func (b *TextBackend) PutMetadata(key string, meta store.Metadata) error {
+	return b.backend.PutMetadata(key, meta)
+}
+
+func (b *TextBackend) Delete(key string) error {
+	return b.backend.Delete(key)
+}
+
+// Revisions returns the revisions of the wrapped backend. The sizes it
+// recorded are of the plaintext, since sealed content reports its own.
+func (b *TextBackend) Revisions(key string) ([]store.Revision, error) {
+	return b.backend.Revisions(key)
+}
+
+func (b *TextBackend) GetRevision(key string, number int) (*store.GetResponse, error) {
+	return b.open(key, func(key string) (*store.GetResponse, error) {
+		return b.backend.GetRevision(key, number)
+	})
+}
+
+func (b *TextBackend) List() ([]string, error) {
+	return b.backend.List()
+}
+
+// open reads sealed content with get and returns its plaintext.
+func (b *TextBackend) open(key string, get func(string) (*store.GetResponse, error)) (*store.GetResponse, error) {
+	resp, err := get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Content.Close()
+
+	content, err := b.cipher.Open(resp.Content, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.GetResponse{Content: plaintext{bytes.NewReader(content)}}, nil
+}
+
+func NewTextBackend(backend store.Backend, cipher *Cipher) *TextBackend {
+	return &TextBackend{
+		backend: backend,
+		cipher:  cipher,
+		writing: make(map[string]*keyLock),
+	}
+}
@@ -13,8 +13,49 @@ const (
 	ErrorTypeUnknown
 	ErrorTypeETagMismatch
 	ErrorTypeInvalidKey
+	ErrorTypeExpired
+	ErrorTypeBadRequest
+	ErrorTypeTooLarge
+	ErrorTypeConflict
+	ErrorTypeUnsupportedFormat
+	ErrorTypeMethodNotAllowed
 )
 
+var errorTypeCodes = map[ErrorType]string{
+	ErrorTypeInvalidPassword:   "invalid_password",
+	ErrorTypeNotFound:          "not_found",
+	ErrorTypeUnknown:           "unknown",
+	ErrorTypeETagMismatch:      "etag_mismatch",
+	ErrorTypeInvalidKey:        "invalid_key",
+	ErrorTypeExpired:           "expired",
+	ErrorTypeBadRequest:        "bad_request",
+	ErrorTypeTooLarge:          "too_large",
+	ErrorTypeConflict:          "conflict",
+	ErrorTypeUnsupportedFormat: "unsupported_format",
+	ErrorTypeMethodNotAllowed:  "method_not_allowed",
+}
+
+// String returns the code the error type is known by in API responses.
+func (t ErrorType) String() string {
+	if code, ok := errorTypeCodes[t]; ok {
+		return code
+	}
+
+	return errorTypeCodes[ErrorTypeUnknown]
+}
+
+// ErrorTypeFromString returns the error type known by code in API responses.
+// Codes it doesn't know are ErrorTypeUnknown.
+func ErrorTypeFromString(code string) ErrorType {
+	for t, c := range errorTypeCodes {
+		if c == code {
+			return t
+		}
+	}
+
+	return ErrorTypeUnknown
+}
+
 type StoreError struct {
 	Type       ErrorType
 	Message    string
@@ -32,6 +73,12 @@ func (se StoreError) Error() string {
 	return se.Message
 }
 
+// Unwrap returns the causes of the error so they can be matched with errors.Is
+// and errors.As.
+func (se StoreError) Unwrap() []error {
+	return se.Causes
+}
+
 func IsStoreError(err error) bool {
 	_, ok := err.(*StoreError)
 
@@ -80,8 +127,48 @@ func NewInvalidKeyError(key string) *StoreError {
 
 func NewUnsupportedFormatError(givenFormat string) *StoreError {
 	return &StoreError{
-		Type:       ErrorTypeUnknown,
+		Type:       ErrorTypeUnsupportedFormat,
 		Message:    fmt.Sprintf("unsupported format: %s", givenFormat),
 		StatusCode: http.StatusUnsupportedMediaType,
 	}
 }
+
+func NewExpiredError(key string) *StoreError {
+	return &StoreError{
+		Type:       ErrorTypeExpired,
+		Message:    fmt.Sprintf("jot under key has expired: %s", key),
+		StatusCode: http.StatusGone,
+	}
+}
+
+func NewBadRequestError(msg string) *StoreError {
+	return &StoreError{
+		Type:       ErrorTypeBadRequest,
+		Message:    msg,
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+func NewConflictError(key string) *StoreError {
+	return &StoreError{
+		Type:       ErrorTypeConflict,
+		Message:    fmt.Sprintf("key is already taken: %s", key),
+		StatusCode: http.StatusConflict,
+	}
+}
+
+func NewTooLargeError(msg string) *StoreError {
+	return &StoreError{
+		Type:       ErrorTypeTooLarge,
+		Message:    msg,
+		StatusCode: http.StatusRequestEntityTooLarge,
+	}
+}
+
+func NewMethodNotAllowedError(method string) *StoreError {
+	return &StoreError{
+		Type:       ErrorTypeMethodNotAllowed,
+		Message:    fmt.Sprintf("method not allowed: %s", method),
+		StatusCode: http.StatusMethodNotAllowed,
+	}
+}
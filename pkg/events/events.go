@@ -0,0 +1,170 @@
+// Package events is an in-process publish/subscribe hub that tells readers
+// when a stored object changes.
+package events
+
+import (
+	"sync"
+
+	"github.com/google/wire"
+)
+
+var ProviderSet = wire.NewSet(
+	NewBroker,
+)
+
+// Type is the kind of change an Event describes.
+type Type string
+
+const (
+	// Appended means content was added to the end of the object.
+	Appended Type = "append"
+	// Updated means the content of the object was replaced.
+	Updated Type = "update"
+	// Deleted means the object is gone.
+	Deleted Type = "delete"
+)
+
+// Event tells subscribers that the object under Key changed. Events don't
+// carry content; subscribers read the object again to see what changed.
+type Event struct {
+	Key  string
+	Type Type
+}
+
+// Broker delivers events to the subscribers of an object key.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+	live        map[string]chan struct{}
+}
+
+// Subscribe returns a channel of events for key and a function that ends the
+// subscription.
+//
+// The channel holds one event. Events published while it's full are combined
+// with the one waiting into the one that says the most: a deletion over a
+// replacement over an append. Since events only say that something changed,
+// a subscriber that reads the object after each event it receives still sees
+// every change, and never misses that the object was replaced or deleted.
+func (b *Broker) Subscribe(key string) (<-chan Event, func()) {
+	ch := make(chan Event, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[key] == nil {
+		b.subscribers[key] = make(map[chan Event]struct{})
+	}
+
+	b.subscribers[key][ch] = struct{}{}
+
+	var once sync.Once
+
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers[key], ch)
+
+			if len(b.subscribers[key]) == 0 {
+				delete(b.subscribers, key)
+			}
+		})
+	}
+}
+
+// Publish sends e to every subscriber of e.Key without blocking, combining it
+// with the event a subscriber hasn't received yet. It's safe to call on a nil
+// Broker, which drops every event.
+func (b *Broker) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[e.Key] {
+		next := e
+
+		select {
+		case waiting := <-ch:
+			next = combine(waiting, e)
+		default:
+		}
+
+		// only Publish sends, with mu held, so there's room now.
+		ch <- next
+	}
+}
+
+// combine returns the event that tells a subscriber about both a and b, which
+// happened after it.
+func combine(a, b Event) Event {
+	if rank(a.Type) > rank(b.Type) {
+		return a
+	}
+
+	return b
+}
+
+// rank orders types by how much of the object a subscriber has to read again
+// to catch up.
+func rank(t Type) int {
+	switch t {
+	case Deleted:
+		return 2
+	case Updated:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// StartLive marks key as having content streamed into it and returns a
+// function that ends the stream. Readers use Live to keep their responses open
+// until then.
+func (b *Broker) StartLive(key string) func() {
+	done := make(chan struct{})
+
+	b.mu.Lock()
+	b.live[key] = done
+	b.mu.Unlock()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.live, key)
+			close(done)
+		})
+	}
+}
+
+// Live returns a channel that's closed when the stream into key ends, or nil
+// if nothing is streaming into key. It's safe to call on a nil Broker.
+func (b *Broker) Live(key string) <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if done, ok := b.live[key]; ok {
+		return done
+	}
+
+	return nil
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan Event]struct{}),
+		live:        make(map[string]chan struct{}),
+	}
+}
@@ -0,0 +1,38 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerCombinesEvents(t *testing.T) {
+	broker := events.NewBroker()
+
+	sub, unsubscribe := broker.Subscribe("abc")
+	defer unsubscribe()
+
+	// a subscriber that falls behind gets the event that says the most.
+	for _, typ := range []events.Type{events.Appended, events.Updated, events.Appended} {
+		broker.Publish(events.Event{Key: "abc", Type: typ})
+	}
+
+	require.Equal(t, events.Updated, (<-sub).Type)
+
+	for _, typ := range []events.Type{events.Deleted, events.Appended, events.Updated} {
+		broker.Publish(events.Event{Key: "abc", Type: typ})
+	}
+
+	require.Equal(t, events.Deleted, (<-sub).Type)
+
+	select {
+	case e := <-sub:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+
+	// events of other keys aren't sent.
+	broker.Publish(events.Event{Key: "other", Type: events.Deleted})
+	require.Empty(t, sub)
+}
@@ -0,0 +1,95 @@
+// Package highlight splits source code into lines of tokens tagged with CSS
+// classes, so pages can render syntax highlighted code with line numbers.
+package highlight
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// ContainerClass is the class the element holding highlighted lines must have
+// for the rules returned by CSS to apply.
+const ContainerClass = "chroma"
+
+// styleName is the chroma style that matches the colors of the jot pages.
+const styleName = "gruvbox"
+
+// Token is a run of text and the CSS class it's drawn with.
+type Token struct {
+	Class string
+	Text  string
+}
+
+// Line is one line of highlighted source, without its line ending.
+type Line []Token
+
+// Lines highlights content as lang, which can be a language name, an alias
+// or a file extension such as "go", "python" or "md". Unknown languages are
+// treated as plain text. The name of the language used is returned with the
+// lines.
+func Lines(lang, content string) ([]Line, string, error) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	lexer = chroma.Coalesce(lexer)
+
+	iter, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var lines []Line
+
+	for _, tokens := range chroma.SplitTokensIntoLines(iter.Tokens()) {
+		line := make(Line, 0, len(tokens))
+
+		for _, t := range tokens {
+			text := strings.TrimSuffix(t.Value, "\n")
+			if text == "" {
+				continue
+			}
+
+			line = append(line, Token{Class: class(t.Type), Text: text})
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, lexer.Config().Name, nil
+}
+
+// class returns the CSS class chroma uses for a token type, falling back to
+// the class of its parent types.
+func class(t chroma.TokenType) string {
+	for ; t != 0; t = t.Parent() {
+		if cls, ok := chroma.StandardTypes[t]; ok {
+			return cls
+		}
+	}
+
+	return chroma.StandardTypes[t]
+}
+
+var css = sync.OnceValue(func() string {
+	var buf bytes.Buffer
+
+	formatter := html.New(html.WithClasses(true))
+	if err := formatter.WriteCSS(&buf, styles.Get(styleName)); err != nil {
+		return ""
+	}
+
+	return buf.String()
+})
+
+// CSS returns the style sheet for the classes returned by Lines.
+func CSS() string {
+	return css()
+}
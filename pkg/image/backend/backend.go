@@ -9,11 +9,22 @@ import (
 
 type StatResponse struct {
 	ModifiedDate time.Time
+	Metadata     Metadata
+}
+
+// Metadata is stored alongside the images of a gallery.
+type Metadata struct {
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
 }
 
 type Interface interface {
 	Stat(ctx context.Context, key string) (*StatResponse, error)
 	Get(ctx context.Context, key string) (*types.Images, error)
-	Create(ctx context.Context, key string, images *types.Images) error
+	// Create stores a new gallery along with its metadata, which is written
+	// first so the gallery is never visible without it.
+	Create(ctx context.Context, key string, images *types.Images, meta Metadata) error
+	PutMetadata(ctx context.Context, key string, meta Metadata) error
 	Delete(ctx context.Context, key string) error
+	// List returns the keys of every gallery in the backend.
+	List(ctx context.Context) ([]string, error)
 }
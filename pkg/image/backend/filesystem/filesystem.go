@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -13,6 +14,8 @@ import (
 	"strings"
 
 	"github.com/google/wire"
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/compression"
 	"github.com/kyleterry/jot/pkg/config"
 	"github.com/kyleterry/jot/pkg/errors"
 	"github.com/kyleterry/jot/pkg/image/backend"
@@ -32,14 +35,20 @@ var BoundProviderSet = wire.NewSet(
 const (
 	directoryName   = "img"
 	galleryFileName = "gallery"
+	metadataName    = "meta"
 )
 
 type Options struct {
 	StorageDir config.DataDir
+	Blobs      *blob.Store
 }
 
+// Backend stores galleries as directories under their ID. Images are kept in
+// the blob store and the gallery file points at them, so an image uploaded
+// many times is stored once.
 type Backend struct {
-	path string
+	path  string
+	blobs *blob.Store
 }
 
 func (b *Backend) Stat(ctx context.Context, id string) (*backend.StatResponse, error) {
@@ -54,14 +63,65 @@ func (b *Backend) Stat(ctx context.Context, id string) (*backend.StatResponse, e
 		return nil, err
 	}
 
-	return &backend.StatResponse{ModifiedDate: stat.ModTime()}, nil
+	meta, err := b.readMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.StatResponse{ModifiedDate: stat.ModTime(), Metadata: meta}, nil
 }
 
 func (b *Backend) Get(ctx context.Context, id string) (*types.Images, error) {
+	entries, err := readGallery(filepath.Join(b.path, id, galleryFileName))
+	if err != nil {
+		return nil, err
+	}
+
 	images := &types.Images{}
 
-	path := filepath.Join(b.path, id, galleryFileName)
+	for _, e := range entries {
+		content, err := b.open(e)
+		if err != nil {
+			for _, image := range images.Values {
+				image.Content.Close()
+			}
+
+			return nil, err
+		}
+
+		images.Add(e.name, &types.ImageData{
+			Name:        e.name,
+			Content:     content,
+			ContentType: e.contentType,
+		})
+	}
+
+	return images, nil
+}
 
+// blobPrefix starts the images of a gallery file that point at a blob. It
+// can't be part of base64, which galleries written before blobs existed hold
+// their images in.
+const blobPrefix = "sha256:"
+
+// entry is one image in a gallery file. It points at the blob that holds the
+// image, or holds the image itself in galleries written before blobs existed.
+type entry struct {
+	name        string
+	contentType string
+	sum         string
+	content     []byte
+}
+
+func (b *Backend) open(e entry) (io.ReadCloser, error) {
+	if e.sum != "" {
+		return b.blobs.Open(e.sum)
+	}
+
+	return io.NopCloser(bytes.NewReader(e.content)), nil
+}
+
+func readGallery(path string) ([]entry, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -69,7 +129,17 @@ func (b *Backend) Get(ctx context.Context, id string) (*types.Images, error) {
 
 	defer f.Close()
 
-	reader := bufio.NewReader(f)
+	// galleries were compressed before their images were kept in blobs.
+	decoded, err := compression.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defer decoded.Close()
+
+	var entries []entry
+
+	reader := bufio.NewReader(decoded)
 
 	for {
 		line, err := reader.ReadString('\n')
@@ -87,30 +157,39 @@ func (b *Backend) Get(ctx context.Context, id string) (*types.Images, error) {
 		}
 
 		metadata := strings.Split(parts[0], ";")
-		name := metadata[0]
 
-		var contentType string
+		// names are escaped when the gallery is written
+		name, err := url.QueryUnescape(metadata[0])
+		if err != nil {
+			return nil, err
+		}
+
+		e := entry{name: name}
+
 		if len(metadata) > 1 {
-			contentType = metadata[1]
+			e.contentType = metadata[1]
 		}
 
-		decoded, err := base64.StdEncoding.DecodeString(parts[1])
-		if err != nil {
-			return nil, err
+		if sum, ok := strings.CutPrefix(parts[1], blobPrefix); ok {
+			if !blob.ValidSum(sum) {
+				return nil, errors.NewUnknownError("malformed blob reference in image gallery")
+			}
+
+			e.sum = sum
+		} else {
+			e.content, err = base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		r := bytes.NewBuffer(decoded)
-		images.Add(name, &types.ImageData{
-			Name:        name,
-			Content:     io.NopCloser(r),
-			ContentType: contentType,
-		})
+		entries = append(entries, e)
 	}
 
-	return images, nil
+	return entries, nil
 }
 
-func (b *Backend) Create(ctx context.Context, id string, images *types.Images) error {
+func (b *Backend) Create(ctx context.Context, id string, images *types.Images, meta backend.Metadata) error {
 	defer func() {
 		for _, c := range images.Values {
 			c.Content.Close()
@@ -119,11 +198,49 @@ func (b *Backend) Create(ctx context.Context, id string, images *types.Images) e
 
 	dir := filepath.Join(b.path, id)
 	if err := os.Mkdir(dir, config.DirectoryPermissions); err != nil {
+		if os.IsExist(err) {
+			return errors.NewConflictError(id).WithCause(err)
+		}
+
 		return err
 	}
 
-	fp := filepath.Join(dir, galleryFileName)
+	var entries []entry
+
+	// a gallery that couldn't be written completely is removed rather than
+	// left behind half written.
+	err := func() error {
+		if err := b.PutMetadata(ctx, id, meta); err != nil {
+			return err
+		}
+
+		for _, name := range images.Keys {
+			image := images.Values[name]
 
+			sum, err := b.blobs.Put(image.Content)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry{name: name, contentType: image.ContentType, sum: sum})
+		}
+
+		return writeGallery(filepath.Join(dir, galleryFileName), entries)
+	}()
+	if err != nil {
+		os.RemoveAll(dir)
+
+		if rerr := b.release(entries); rerr != nil {
+			return fmt.Errorf("%w (and failed to release blobs: %v)", err, rerr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func writeGallery(fp string, entries []entry) error {
 	galleryFile, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, config.FilePermissions)
 	if err != nil {
 		return err
@@ -131,19 +248,28 @@ func (b *Backend) Create(ctx context.Context, id string, images *types.Images) e
 
 	defer galleryFile.Close()
 
-	for _, imageName := range images.Keys {
-		imageData := images.Values[imageName]
-		buf := &bytes.Buffer{}
+	for _, e := range entries {
+		ref := blobPrefix + e.sum
+		if e.sum == "" {
+			ref = base64.StdEncoding.EncodeToString(e.content)
+		}
 
-		_, err := buf.ReadFrom(imageData.Content)
-		if err != nil {
+		if _, err := fmt.Fprintf(galleryFile, "%s;%s %s\n", url.QueryEscape(e.name), e.contentType, ref); err != nil {
 			return err
 		}
+	}
 
-		imageName = url.QueryEscape(imageName)
+	return galleryFile.Close()
+}
 
-		encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
-		if _, err := fmt.Fprintf(galleryFile, "%s;%s %s\n", imageName, imageData.ContentType, encoded); err != nil {
+// release removes the references of entries from the blobs they point at.
+func (b *Backend) release(entries []entry) error {
+	for _, e := range entries {
+		if e.sum == "" {
+			continue
+		}
+
+		if err := b.blobs.Unref(e.sum); err != nil {
 			return err
 		}
 	}
@@ -151,8 +277,67 @@ func (b *Backend) Create(ctx context.Context, id string, images *types.Images) e
 	return nil
 }
 
+// PutMetadata writes the metadata for a gallery, replacing any that already
+// exists. The gallery must have been created first.
+func (b *Backend) PutMetadata(ctx context.Context, id string, meta backend.Metadata) error {
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(b.path, id, metadataName), raw, config.FilePermissions)
+}
+
+func (b *Backend) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+
+	return ids, nil
+}
+
+// readMetadata loads the metadata for a gallery. Galleries created before
+// metadata existed have no file, and get empty metadata.
+func (b *Backend) readMetadata(id string) (backend.Metadata, error) {
+	var meta backend.Metadata
+
+	raw, err := os.ReadFile(filepath.Join(b.path, id, metadataName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+
+		return meta, err
+	}
+
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return meta, err
+	}
+
+	return meta, nil
+}
+
 func (b *Backend) Delete(ctx context.Context, id string) error {
 	dir := filepath.Join(b.path, id)
+
+	entries, err := readGallery(filepath.Join(dir, galleryFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := b.release(entries); err != nil {
+		return errors.NewUnknownError("failed to release images of gallery").WithCause(err)
+	}
+
 	if err := os.RemoveAll(dir); err != nil {
 		return errors.NewUnknownError("failed to delete gallery from filesystem").WithCause(err)
 	}
@@ -160,6 +345,105 @@ func (b *Backend) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Rewrite passes every image of every gallery to fn and replaces it with what
+// fn returns. Modified dates are kept, so ETags don't change. Galleries where
+// fn returns every image unchanged aren't written again. It's meant for one-off
+// migrations of the data dir.
+func (b *Backend) Rewrite(ctx context.Context, fn func(id, name string, content []byte) ([]byte, error)) error {
+	ids, err := b.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := b.rewriteGallery(ctx, id, fn); err != nil {
+			return fmt.Errorf("failed to rewrite gallery %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) rewriteGallery(ctx context.Context, id string, fn func(id, name string, content []byte) ([]byte, error)) error {
+	path := filepath.Join(b.path, id, galleryFileName)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readGallery(path)
+	if err != nil {
+		return err
+	}
+
+	// old are the entries that are replaced and added the ones that replace
+	// them, so whichever aren't needed in the end can be released.
+	var old, added []entry
+
+	rewrite := func() error {
+		for i, e := range entries {
+			r, err := b.open(e)
+			if err != nil {
+				return err
+			}
+
+			content, err := io.ReadAll(r)
+			r.Close()
+
+			if err != nil {
+				return err
+			}
+
+			rewritten, err := fn(id, e.name, content)
+			if err != nil {
+				return err
+			}
+
+			if bytes.Equal(content, rewritten) {
+				continue
+			}
+
+			sum, err := b.blobs.Put(bytes.NewReader(rewritten))
+			if err != nil {
+				return err
+			}
+
+			old = append(old, e)
+			entries[i] = entry{name: e.name, contentType: e.contentType, sum: sum}
+			added = append(added, entries[i])
+		}
+
+		if len(added) == 0 {
+			return nil
+		}
+
+		tmp := path + ".tmp"
+
+		defer os.Remove(tmp)
+
+		if err := writeGallery(tmp, entries); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+
+		return os.Rename(tmp, path)
+	}
+
+	if err := rewrite(); err != nil {
+		if rerr := b.release(added); rerr != nil {
+			return fmt.Errorf("%w (and failed to release blobs: %v)", err, rerr)
+		}
+
+		return err
+	}
+
+	return b.release(old)
+}
+
 func New(opts *Options) (*Backend, error) {
 	store := filepath.Join(string(opts.StorageDir), directoryName)
 	if err := os.MkdirAll(store, config.DirectoryPermissions); err != nil {
@@ -167,6 +451,7 @@ func New(opts *Options) (*Backend, error) {
 	}
 
 	return &Backend{
-		path: store,
+		path:  store,
+		blobs: opts.Blobs,
 	}, nil
 }
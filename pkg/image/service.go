@@ -10,6 +10,6 @@ import (
 type StoreService interface {
 	Stat(ctx context.Context, id string) (*types.GalleryFile, error)
 	Get(ctx context.Context, id string) (*types.GalleryFile, error)
-	Create(ctx context.Context, content *types.Images) (*types.GalleryFile, error)
+	Create(ctx context.Context, content *types.Images, opts types.CreateOptions) (*types.GalleryFile, error)
 	Delete(ctx context.Context, gf *types.GalleryFile) error
 }
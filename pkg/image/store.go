@@ -9,6 +9,7 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"time"
 
 	"github.com/disintegration/imageorient"
 	"github.com/google/wire"
@@ -37,6 +38,10 @@ func (s *Store) stat(ctx context.Context, key string) (*backend.StatResponse, er
 		return nil, errors.NewUnknownError("failed to get file from backend").WithCause(err)
 	}
 
+	if objectMeta(resp).Expired(time.Now()) {
+		return nil, errors.NewExpiredError(key)
+	}
+
 	return resp, nil
 }
 
@@ -46,7 +51,7 @@ func (s *Store) Stat(ctx context.Context, key string) (*types.GalleryFile, error
 		return nil, err
 	}
 
-	return &types.GalleryFile{ID: key, ObjectMeta: types.ObjectMeta{ModifiedDate: resp.ModifiedDate}}, nil
+	return &types.GalleryFile{ID: key, ObjectMeta: objectMeta(resp)}, nil
 }
 
 func (s *Store) Get(ctx context.Context, key string) (*types.GalleryFile, error) {
@@ -63,14 +68,14 @@ func (s *Store) Get(ctx context.Context, key string) (*types.GalleryFile, error)
 	gallery := &types.GalleryFile{
 		ID:         key,
 		Images:     images,
-		ObjectMeta: types.ObjectMeta{ModifiedDate: statResp.ModifiedDate},
+		ObjectMeta: objectMeta(statResp),
 	}
 
 	return gallery, nil
 }
 
-func (s *Store) Create(ctx context.Context, images *types.Images) (*types.GalleryFile, error) {
-	key, password, err := store.NewIDAndPassword(s.opts.IDManager, s.opts.PasswordManager)
+func (s *Store) Create(ctx context.Context, images *types.Images, opts types.CreateOptions) (*types.GalleryFile, error) {
+	key, password, err := store.KeyAndPassword(opts.Key, s.opts.IDManager, s.opts.PasswordManager)
 	if err != nil {
 		return nil, err
 	}
@@ -79,13 +84,14 @@ func (s *Store) Create(ctx context.Context, images *types.Images) (*types.Galler
 		return nil, fmt.Errorf("failed to process images: %w", err)
 	}
 
-	if err := s.storageBackend.Create(ctx, key, images); err != nil {
+	if err := s.storageBackend.Create(ctx, key, images, backend.Metadata{ExpiresAt: opts.ExpiresAt}); err != nil {
 		return nil, err
 	}
 
 	g := types.GalleryFile{
-		ID:       key,
-		Password: password,
+		ID:         key,
+		Password:   password,
+		ObjectMeta: types.ObjectMeta{ExpiresAt: opts.ExpiresAt},
 	}
 
 	return &g, nil
@@ -99,6 +105,41 @@ func (s *Store) Delete(ctx context.Context, gf *types.GalleryFile) error {
 	return nil
 }
 
+// Reap deletes every gallery whose expiry has passed at now and returns the
+// number of galleries deleted.
+func (s *Store) Reap(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.storageBackend.List(ctx)
+	if err != nil {
+		return 0, errors.NewUnknownError("failed to list galleries in backend").WithCause(err)
+	}
+
+	var reaped int
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return reaped, err
+		}
+
+		resp, err := s.storageBackend.Stat(ctx, id)
+		if err != nil {
+			// the gallery was most likely deleted since it was listed
+			continue
+		}
+
+		if !objectMeta(resp).Expired(now) {
+			continue
+		}
+
+		if err := s.storageBackend.Delete(ctx, id); err != nil {
+			return reaped, errors.NewUnknownError("failed to delete gallery from backend").WithCause(err)
+		}
+
+		reaped++
+	}
+
+	return reaped, nil
+}
+
 func (s *Store) processImages(images *types.Images) error {
 	for _, imageName := range images.Keys {
 		imageData := images.Values[imageName]
@@ -156,6 +197,13 @@ func (s *Store) processImages(images *types.Images) error {
 	return nil
 }
 
+func objectMeta(resp *backend.StatResponse) types.ObjectMeta {
+	return types.ObjectMeta{
+		ModifiedDate: resp.ModifiedDate,
+		ExpiresAt:    resp.Metadata.ExpiresAt,
+	}
+}
+
 func NewStore(b backend.Interface, opts *store.Options) *Store {
 	return &Store{
 		opts:           opts,
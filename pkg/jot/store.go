@@ -1,12 +1,20 @@
 package jot
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"log"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/google/wire"
 	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
 	jotbackend "github.com/kyleterry/jot/pkg/jot/store"
+	"github.com/kyleterry/jot/pkg/search"
 	"github.com/kyleterry/jot/pkg/store"
 	"github.com/kyleterry/jot/pkg/types"
 )
@@ -19,6 +27,9 @@ var ProviderSet = wire.NewSet(
 type TextStore struct {
 	opts    *store.Options
 	backend jotbackend.Backend
+	// claiming is held while a jot is created under a key chosen by the
+	// client, so two clients can't both get the same key.
+	claiming sync.Mutex
 }
 
 func (s *TextStore) stat(key string) (*jotbackend.StatResponse, error) {
@@ -31,6 +42,10 @@ func (s *TextStore) stat(key string) (*jotbackend.StatResponse, error) {
 		return nil, errors.NewUnknownError("failed to get file from backend").WithCause(err)
 	}
 
+	if objectMeta(resp).Expired(time.Now()) {
+		return nil, errors.NewExpiredError(key)
+	}
+
 	return resp, nil
 }
 
@@ -47,13 +62,26 @@ func (s *TextStore) getFile(key string) (*jotbackend.GetResponse, error) {
 	return resp, nil
 }
 
+func (s *TextStore) takeFile(key string) (*jotbackend.GetResponse, error) {
+	resp, err := s.backend.Take(key)
+	if err != nil {
+		if errors.IsStoreError(err) {
+			return nil, err
+		}
+
+		return nil, errors.NewUnknownError("failed to take file from backend").WithCause(err)
+	}
+
+	return resp, nil
+}
+
 func (s *TextStore) Stat(ctx context.Context, key string) (*types.TextFile, error) {
 	resp, err := s.stat(key)
 	if err != nil {
 		return nil, err
 	}
 
-	return &types.TextFile{Key: key, ObjectMeta: types.ObjectMeta{ModifiedDate: resp.ModifiedDate}}, nil
+	return textFile(key, resp), nil
 }
 
 func (s *TextStore) Get(ctx context.Context, key string) (*types.TextFile, error) {
@@ -62,53 +90,403 @@ func (s *TextStore) Get(ctx context.Context, key string) (*types.TextFile, error
 		return nil, err
 	}
 
-	resp, err := s.getFile(key)
+	// jots that burn after reading are taken out of the backend so that no
+	// two readers can both get the content.
+	get := s.getFile
+	if statResp.Metadata.BurnAfterReading {
+		get = s.takeFile
+	}
+
+	resp, err := get(key)
 	if err != nil {
 		return nil, err
 	}
 
-	jotFile := &types.TextFile{
-		Key:        key,
-		Content:    resp.Content,
-		ObjectMeta: types.ObjectMeta{ModifiedDate: statResp.ModifiedDate},
-	}
+	jotFile := textFile(key, statResp)
+	jotFile.Content = resp.Content
 
 	return jotFile, nil
 }
 
-func (s *TextStore) Create(ctx context.Context, content io.ReadCloser) (*types.TextFile, error) {
-	key, password, err := store.NewIDAndPassword(s.opts.IDManager, s.opts.PasswordManager)
+func (s *TextStore) Create(ctx context.Context, content io.ReadCloser, opts types.CreateOptions) (*types.TextFile, error) {
+	key, password, err := store.KeyAndPassword(opts.Key, s.opts.IDManager, s.opts.PasswordManager)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Key != "" {
+		s.claiming.Lock()
+		defer s.claiming.Unlock()
+
+		if err := s.claim(key); err != nil {
+			return nil, err
+		}
+	}
+
+	// metadata is written before the content so the jot is never visible
+	// without it.
+	meta := jotbackend.Metadata{
+		ExpiresAt:        opts.ExpiresAt,
+		BurnAfterReading: opts.BurnAfterReading,
+		ContentType:      opts.ContentType,
+		Filename:         opts.Filename,
+		Encrypted:        opts.Encrypted,
+		ForkOf:           opts.ForkOf,
+	}
+
+	if err := s.backend.PutMetadata(key, meta); err != nil {
+		return nil, errors.NewUnknownError("failed to write metadata into backend").WithCause(err)
+	}
+
 	if err := s.backend.Put(key, content); err != nil {
+		s.backend.Delete(key)
+
 		return nil, errors.NewUnknownError("failed to write file into backend").WithCause(err)
 	}
 
+	s.index(key)
+
 	return &types.TextFile{
-		Key:      key,
-		Content:  content,
-		Password: password,
+		Key:              key,
+		Content:          content,
+		Password:         password,
+		BurnAfterReading: opts.BurnAfterReading,
+		ContentType:      opts.ContentType,
+		Filename:         opts.Filename,
+		Encrypted:        opts.Encrypted,
+		ForkOf:           opts.ForkOf,
+		ObjectMeta:       types.ObjectMeta{ExpiresAt: opts.ExpiresAt},
 	}, nil
 }
 
 func (s *TextStore) Update(ctx context.Context, jotFile *types.TextFile) error {
-	if err := s.backend.Put(jotFile.Key, jotFile.Content); err != nil {
+	if err := s.put(jotFile.Key, jotFile.Content); err != nil {
+		return err
+	}
+
+	s.index(jotFile.Key)
+	s.publish(jotFile.Key, events.Updated)
+
+	return nil
+}
+
+func (s *TextStore) put(key string, content io.ReadCloser) error {
+	if err := s.backend.Put(key, content); err != nil {
 		return errors.NewUnknownError("failed to write file into backend").WithCause(err)
 	}
 
 	return nil
 }
 
+// Append adds content to the end of a jot. Backends that can't append in place
+// get the whole jot rewritten.
+func (s *TextStore) Append(ctx context.Context, jotFile *types.TextFile, content io.ReadCloser) error {
+	// only what's appended is indexed, so it's kept while it's written.
+	var appended bytes.Buffer
+	if s.opts.Search != nil {
+		content = readCloser{io.TeeReader(content, &appended), content}
+	}
+
+	if appender, ok := s.backend.(jotbackend.Appender); ok {
+		if err := appender.Append(jotFile.Key, content); err != nil {
+			if errors.IsStoreError(err) {
+				return err
+			}
+
+			return errors.NewUnknownError("failed to append to file in backend").WithCause(err)
+		}
+
+		s.extendIndex(jotFile.Key, &appended)
+		s.publish(jotFile.Key, events.Appended)
+
+		return nil
+	}
+
+	resp, err := s.getFile(jotFile.Key)
+	if err != nil {
+		content.Close()
+
+		return err
+	}
+
+	defer resp.Content.Close()
+
+	if err := s.put(jotFile.Key, readCloser{io.MultiReader(resp.Content, content), content}); err != nil {
+		return err
+	}
+
+	s.extendIndex(jotFile.Key, &appended)
+	s.publish(jotFile.Key, events.Appended)
+
+	return nil
+}
+
 func (s *TextStore) Delete(ctx context.Context, jotFile *types.TextFile) error {
 	if err := s.backend.Delete(jotFile.Key); err != nil {
 		return errors.NewUnknownError("failed to delete file from backend").WithCause(err)
 	}
 
+	s.unindex(jotFile.Key)
+	s.publish(jotFile.Key, events.Deleted)
+
 	return nil
 }
 
+// Revisions returns every revision of a jot, oldest first.
+func (s *TextStore) Revisions(ctx context.Context, key string) ([]*types.Revision, error) {
+	if _, err := s.stat(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.backend.Revisions(key)
+	if err != nil {
+		if errors.IsStoreError(err) {
+			return nil, err
+		}
+
+		return nil, errors.NewUnknownError("failed to list revisions in backend").WithCause(err)
+	}
+
+	revisions := make([]*types.Revision, 0, len(resp))
+	for _, rev := range resp {
+		// content written before its size was recorded is measured.
+		if rev.Size < 0 {
+			if rev.Size, err = s.measure(key, rev.Number); err != nil {
+				return nil, errors.NewUnknownError("failed to read revision in backend").WithCause(err)
+			}
+		}
+
+		revisions = append(revisions, &types.Revision{
+			Number:     rev.Number,
+			Size:       rev.Size,
+			ObjectMeta: types.ObjectMeta{ModifiedDate: rev.ModifiedDate},
+		})
+	}
+
+	return revisions, nil
+}
+
+// measure returns the size of revision number of key by reading it.
+func (s *TextStore) measure(key string, number int) (int64, error) {
+	resp, err := s.backend.GetRevision(key, number)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Content.Close()
+
+	return io.Copy(io.Discard, resp.Content)
+}
+
+// GetRevision returns the content of a jot as it was at revision number.
+// Jots that burn after reading never give out their content this way.
+func (s *TextStore) GetRevision(ctx context.Context, key string, number int) (*types.TextFile, error) {
+	statResp, err := s.stat(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if statResp.Metadata.BurnAfterReading {
+		return nil, errors.NewNotFoundError(key)
+	}
+
+	revisions, err := s.Revisions(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(revisions, func(rev *types.Revision) bool {
+		return rev.Number == number
+	})
+	if idx < 0 {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("%s revision %d", key, number))
+	}
+
+	resp, err := s.backend.GetRevision(key, number)
+	if err != nil {
+		if errors.IsStoreError(err) {
+			return nil, err
+		}
+
+		return nil, errors.NewUnknownError("failed to get revision from backend").WithCause(err)
+	}
+
+	return &types.TextFile{
+		Key:        key,
+		Content:    resp.Content,
+		ObjectMeta: revisions[idx].ObjectMeta,
+	}, nil
+}
+
+// Restore makes the content of revision number the current content of a jot.
+// The content it replaces is kept as a revision like any other update.
+func (s *TextStore) Restore(ctx context.Context, jotFile *types.TextFile, number int) error {
+	rev, err := s.GetRevision(ctx, jotFile.Key, number)
+	if err != nil {
+		return err
+	}
+
+	jotFile.Content = rev.Content
+
+	return s.Update(ctx, jotFile)
+}
+
+// Fork copies the content and metadata of a jot into a new jot, with its own
+// key and password, that records the jot it was forked from. Jots that burn
+// after reading can't be forked, since reading them deletes them.
+func (s *TextStore) Fork(ctx context.Context, jotFile *types.TextFile) (*types.TextFile, error) {
+	if jotFile.BurnAfterReading {
+		return nil, errors.NewBadRequestError("jots that burn after reading can't be forked")
+	}
+
+	resp, err := s.getFile(jotFile.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Content.Close()
+
+	return s.Create(ctx, resp.Content, types.CreateOptions{
+		ExpiresAt:   jotFile.ExpiresAt,
+		ContentType: jotFile.ContentType,
+		Filename:    jotFile.Filename,
+		Encrypted:   jotFile.Encrypted,
+		ForkOf:      jotFile.Key,
+	})
+}
+
+// Reap deletes every jot whose expiry has passed at now and returns the number
+// of jots deleted.
+func (s *TextStore) Reap(ctx context.Context, now time.Time) (int, error) {
+	keys, err := s.backend.List()
+	if err != nil {
+		return 0, errors.NewUnknownError("failed to list files in backend").WithCause(err)
+	}
+
+	var reaped int
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return reaped, err
+		}
+
+		resp, err := s.backend.Stat(key)
+		if err != nil {
+			// the jot was most likely deleted since it was listed
+			continue
+		}
+
+		if !objectMeta(resp).Expired(now) {
+			continue
+		}
+
+		if err := s.backend.Delete(key); err != nil {
+			return reaped, errors.NewUnknownError("failed to delete file from backend").WithCause(err)
+		}
+
+		s.unindex(key)
+		s.publish(key, events.Deleted)
+
+		reaped++
+	}
+
+	return reaped, nil
+}
+
+func (s *TextStore) publish(key string, t events.Type) {
+	s.opts.Events.Publish(events.Event{Key: key, Type: t})
+}
+
+// claim checks that no jot exists under key. Jots that expired but weren't
+// reaped yet still hold their key.
+func (s *TextStore) claim(key string) error {
+	_, err := s.backend.Stat(key)
+	if err == nil {
+		return errors.NewConflictError(key)
+	}
+
+	if storeErr, ok := err.(*errors.StoreError); ok && storeErr.Type == errors.ErrorTypeNotFound {
+		return nil
+	}
+
+	return errors.NewUnknownError("failed to get file from backend").WithCause(err)
+}
+
+// index adds the current content of a jot to the search index. A jot that
+// fails to be indexed is still written; it just can't be found until it's
+// written again or the index is rebuilt.
+func (s *TextStore) index(key string) {
+	if s.opts.Search == nil {
+		return
+	}
+
+	resp, err := s.backend.Stat(key)
+	if err != nil || !search.Indexable(resp.Metadata) {
+		return
+	}
+
+	content, err := s.getFile(key)
+	if err != nil {
+		log.Printf("[search] failed to index %s: %s", key, err)
+
+		return
+	}
+
+	defer content.Content.Close()
+
+	if err := s.opts.Search.Add(key, resp.ModifiedDate, content.Content); err != nil {
+		log.Printf("[search] failed to index %s: %s", key, err)
+	}
+}
+
+// extendIndex adds content appended to a jot to the search index.
+func (s *TextStore) extendIndex(key string, appended io.Reader) {
+	if s.opts.Search == nil {
+		return
+	}
+
+	resp, err := s.backend.Stat(key)
+	if err != nil || !search.Indexable(resp.Metadata) {
+		return
+	}
+
+	if err := s.opts.Search.Extend(key, resp.ModifiedDate, appended); err != nil {
+		log.Printf("[search] failed to index %s: %s", key, err)
+	}
+}
+
+func (s *TextStore) unindex(key string) {
+	if err := s.opts.Search.Remove(key); err != nil {
+		log.Printf("[search] failed to remove %s from the index: %s", key, err)
+	}
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func objectMeta(resp *jotbackend.StatResponse) types.ObjectMeta {
+	return types.ObjectMeta{
+		ModifiedDate: resp.ModifiedDate,
+		ExpiresAt:    resp.Metadata.ExpiresAt,
+	}
+}
+
+// textFile returns the jot described by resp, without its content.
+func textFile(key string, resp *jotbackend.StatResponse) *types.TextFile {
+	return &types.TextFile{
+		Key:              key,
+		BurnAfterReading: resp.Metadata.BurnAfterReading,
+		ContentType:      resp.Metadata.ContentType,
+		Filename:         resp.Metadata.Filename,
+		Encrypted:        resp.Metadata.Encrypted,
+		ForkOf:           resp.Metadata.ForkOf,
+		ObjectMeta:       objectMeta(resp),
+	}
+}
+
 func NewStore(backend jotbackend.Backend, opts *store.Options) *TextStore {
 	return &TextStore{
 		opts:    opts,
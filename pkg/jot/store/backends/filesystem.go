@@ -1,12 +1,21 @@
 package backends
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/wire"
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/compression"
 	"github.com/kyleterry/jot/pkg/config"
 	"github.com/kyleterry/jot/pkg/errors"
 	"github.com/kyleterry/jot/pkg/jot/store"
@@ -17,6 +26,15 @@ var ProviderSet = wire.NewSet(
 	NewFilesystem,
 )
 
+// These suffixes are appended to a key to build the names of the files that
+// belong to it. Keys never contain a dot so they can't collide.
+const (
+	metadataSuffix  = ".meta"
+	revisionsSuffix = ".revs"
+)
+
+var _ store.Appender = (*Filesystem)(nil)
+
 var BoundProviderSet = wire.NewSet(
 	ProviderSet,
 	wire.Bind(new(store.Backend), new(*Filesystem)),
@@ -36,10 +54,16 @@ type FilesystemOptions struct {
 	DirectoryPermissions os.FileMode
 }
 
+// Filesystem stores jots as files under their key. The content of a jot is
+// kept in the blob store and its file points at it, so identical jots and
+// revisions are stored once.
 type Filesystem struct {
+	// mu serializes writes so revisions are numbered in order.
+	mu                   sync.Mutex
 	path                 string
 	filePermissions      os.FileMode
 	directoryPermissions os.FileMode
+	blobs                *blob.Store
 }
 
 func (fs *Filesystem) Stat(key string) (*store.StatResponse, error) {
@@ -54,17 +78,36 @@ func (fs *Filesystem) Stat(key string) (*store.StatResponse, error) {
 		return nil, err
 	}
 
-	return &store.StatResponse{ModifiedDate: stat.ModTime()}, nil
+	meta, err := fs.readMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.StatResponse{ModifiedDate: stat.ModTime(), Metadata: meta}, nil
 }
 
 func (fs *Filesystem) Get(key string) (*store.GetResponse, error) {
 	path := filepath.Join(fs.path, key)
 
-	f, err := os.Open(path)
+	resp, err := fs.openFile(path)
 	if err != nil {
-		if f != nil {
-			f.Close()
+		if os.IsNotExist(err) {
+			return nil, errors.NewNotFoundError(key).WithCause(err)
 		}
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (fs *Filesystem) Take(key string) (*store.GetResponse, error) {
+	path := filepath.Join(fs.path, key)
+	taken := fmt.Sprintf("%s.taken-%d", path, time.Now().UnixNano())
+
+	// rename is atomic, so only one caller can move the file out from under
+	// the key.
+	if err := os.Rename(path, taken); err != nil {
 		if os.IsNotExist(err) {
 			return nil, errors.NewNotFoundError(key).WithCause(err)
 		}
@@ -72,39 +115,542 @@ func (fs *Filesystem) Get(key string) (*store.GetResponse, error) {
 		return nil, err
 	}
 
-	return &store.GetResponse{Content: f}, nil
+	resp, err := fs.openFile(taken)
+	if err != nil {
+		return nil, err
+	}
+
+	// open files stay readable after the blobs they belong to are deleted.
+	if err := fs.remove(key, taken); err != nil {
+		resp.Content.Close()
+
+		return nil, err
+	}
+
+	return resp, nil
 }
 
 func (fs *Filesystem) Put(key string, content io.ReadCloser) error {
+	defer content.Close()
+
 	path := filepath.Join(fs.path, key)
 
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fs.filePermissions)
+	r, err := fs.putBlob(content)
+	if err != nil {
+		return err
+	}
 
-	defer func() {
-		f.Close()
-		content.Close()
-	}()
+	// the new file is written next to the current one and renamed over it, so
+	// readers never see a partially written jot.
+	if err := fs.putRefs(key, path, r); err != nil {
+		if uerr := fs.blobs.Unref(r.sum); uerr != nil {
+			return fmt.Errorf("%w (and failed to release blob: %v)", err, uerr)
+		}
 
+		return err
+	}
+
+	return nil
+}
+
+func (fs *Filesystem) putRefs(key, path string, r ref) error {
+	f, err := os.CreateTemp(fs.path, key+".tmp-*")
 	if err != nil {
 		return err
 	}
 
-	buf := bufio.NewReader(content)
+	defer os.Remove(f.Name())
 
-	if _, err := buf.WriteTo(f); err != nil {
+	if err := fs.writeTemp(f, bytes.NewReader(encodeRefs(r))); err != nil {
 		return err
 	}
 
-	return nil
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.archive(key); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (fs *Filesystem) writeTemp(f *os.File, content io.Reader) error {
+	defer f.Close()
+
+	if err := f.Chmod(fs.filePermissions); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, content); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// archive keeps the current content of key as its next revision. The revision
+// is a hard link to the current file so it keeps the modified date it was
+// written with. The current file is replaced right after, so the references
+// it held to blobs move to the revision.
+func (fs *Filesystem) archive(key string) error {
+	path := filepath.Join(fs.path, key)
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	numbers, err := fs.archivedRevisions(key)
+	if err != nil {
+		return err
+	}
+
+	dir := fs.revisionsPath(key)
+	if err := os.MkdirAll(dir, fs.directoryPermissions); err != nil {
+		return err
+	}
+
+	next := strconv.Itoa(len(numbers) + 1)
+
+	return os.Link(path, filepath.Join(dir, next))
+}
+
+func (fs *Filesystem) Revisions(key string) ([]store.Revision, error) {
+	stat, err := os.Stat(filepath.Join(fs.path, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NewNotFoundError(key).WithCause(err)
+		}
+
+		return nil, err
+	}
+
+	numbers, err := fs.archivedRevisions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]store.Revision, 0, len(numbers)+1)
+
+	for _, n := range numbers {
+		path := filepath.Join(fs.revisionsPath(key), strconv.Itoa(n))
+
+		rstat, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := fs.size(path)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, store.Revision{
+			Number:       n,
+			ModifiedDate: rstat.ModTime(),
+			Size:         size,
+		})
+	}
+
+	size, err := fs.size(filepath.Join(fs.path, key))
+	if err != nil {
+		return nil, err
+	}
+
+	revisions = append(revisions, store.Revision{
+		Number:       len(numbers) + 1,
+		ModifiedDate: stat.ModTime(),
+		Size:         size,
+	})
+
+	return revisions, nil
+}
+
+func (fs *Filesystem) GetRevision(key string, number int) (*store.GetResponse, error) {
+	numbers, err := fs.archivedRevisions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if number == len(numbers)+1 {
+		return fs.Get(key)
+	}
+
+	if !slices.Contains(numbers, number) {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("%s revision %d", key, number))
+	}
+
+	return fs.openFile(filepath.Join(fs.revisionsPath(key), strconv.Itoa(number)))
+}
+
+// archivedRevisions returns the sorted numbers of every revision of key that
+// is not the current content.
+func (fs *Filesystem) archivedRevisions(key string) ([]int, error) {
+	entries, err := os.ReadDir(fs.revisionsPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var numbers []int
+
+	for _, entry := range entries {
+		n, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		numbers = append(numbers, n)
+	}
+
+	slices.Sort(numbers)
+
+	return numbers, nil
+}
+
+func (fs *Filesystem) Append(key string, content io.ReadCloser) error {
+	defer content.Close()
+
+	path := filepath.Join(fs.path, key)
+
+	// the lock keeps a concurrent Put from archiving the file while it's
+	// being appended to.
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, fs.filePermissions)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.NewNotFoundError(key).WithCause(err)
+		}
+
+		return err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	refs, ok, err := readRefs(path)
+	if err != nil {
+		return err
+	}
+
+	// content appended to a small last blob replaces it with a blob of both,
+	// so jots appended to a little at a time don't point at a blob for
+	// every append.
+	if ok && len(refs) > 0 && refs[len(refs)-1].size >= 0 && refs[len(refs)-1].size < appendBlobSize {
+		return fs.extendLastBlob(path, refs, content)
+	}
+
+	// jots that point at blobs point at one more. The current file is never
+	// a revision too, so it can change in place.
+	if ok {
+		r, err := fs.putBlob(content)
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.WriteString(r.line()); err != nil {
+			if terr := f.Truncate(info.Size()); terr != nil {
+				return fmt.Errorf("%w (and failed to truncate: %v)", err, terr)
+			}
+
+			if uerr := fs.blobs.Unref(r.sum); uerr != nil {
+				return fmt.Errorf("%w (and failed to release blob: %v)", err, uerr)
+			}
+
+			return err
+		}
+
+		return f.Close()
+	}
+
+	// content appended to jots that hold their content is compressed like
+	// the content it's appended to.
+	algorithm, _, err := compression.ReadHeader(f)
+	if err != nil {
+		return err
+	}
+
+	// content that can't be appended completely is cut off again so the jot
+	// is left as it was.
+	if err := compression.Append(f, content, algorithm); err != nil {
+		if terr := f.Truncate(info.Size()); terr != nil {
+			return fmt.Errorf("%w (and failed to truncate: %v)", err, terr)
+		}
+
+		return err
+	}
+
+	return f.Close()
+}
+
+// extendLastBlob replaces the last blob the file at path points at with one
+// that holds its content followed by content. The file is replaced rather than
+// changed in place since its last line changes.
+func (fs *Filesystem) extendLastBlob(path string, refs []ref, content io.Reader) error {
+	last := refs[len(refs)-1]
+
+	r, err := fs.extendBlob(last, content)
+	if err != nil {
+		return err
+	}
+
+	refs = append(refs[:len(refs)-1:len(refs)-1], r)
+
+	if err := fs.replaceFile(path, encodeRefs(refs...), time.Now()); err != nil {
+		if uerr := fs.blobs.Unref(r.sum); uerr != nil {
+			return fmt.Errorf("%w (and failed to release blob: %v)", err, uerr)
+		}
+
+		return err
+	}
+
+	return fs.blobs.Unref(last.sum)
+}
+
+// PutMetadata writes the metadata for key, replacing any that already exists.
+func (fs *Filesystem) PutMetadata(key string, meta store.Metadata) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fs.metadataPath(key), b, fs.filePermissions)
 }
 
 func (fs *Filesystem) Delete(key string) error {
 	path := filepath.Join(fs.path, key)
 
-	return os.Remove(path)
+	// a jot whose content failed to write still has its metadata, which has
+	// to go too. The jot is reported missing all the same.
+	_, statErr := os.Stat(path)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	if err := fs.remove(key, path); err != nil {
+		return err
+	}
+
+	return statErr
+}
+
+// remove deletes the file of key at path along with its metadata and
+// revisions, and releases the blobs they point at.
+func (fs *Filesystem) remove(key, path string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	paths, err := fs.revisionPaths(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range append(paths, path) {
+		if err := fs.release(p); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(fs.metadataPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.RemoveAll(fs.revisionsPath(key)); err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// revisionPaths returns the paths of the archived revisions of key.
+func (fs *Filesystem) revisionPaths(key string) ([]string, error) {
+	numbers, err := fs.archivedRevisions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, n := range numbers {
+		paths = append(paths, filepath.Join(fs.revisionsPath(key), strconv.Itoa(n)))
+	}
+
+	return paths, nil
+}
+
+func (fs *Filesystem) List() ([]string, error) {
+	entries, err := os.ReadDir(fs.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.Contains(entry.Name(), ".") {
+			continue
+		}
+
+		keys = append(keys, entry.Name())
+	}
+
+	return keys, nil
+}
+
+// Rewrite passes the content of every jot and every archived revision to fn
+// and replaces it with what fn returns. Modified dates are kept, so ETags
+// don't change. Content fn returns unchanged isn't written again. It's meant
+// for one-off migrations of the data dir that encode content differently, so
+// the size recorded is still that of the content passed to fn.
+func (fs *Filesystem) Rewrite(fn func(key string, content []byte) ([]byte, error)) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	keys, err := fs.List()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		paths, err := fs.revisionPaths(key)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range append(paths, filepath.Join(fs.path, key)) {
+			if err := fs.rewriteFile(path, func(content []byte) ([]byte, error) {
+				return fn(key, content)
+			}); err != nil {
+				return fmt.Errorf("failed to rewrite %s: %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (fs *Filesystem) rewriteFile(path string, fn func([]byte) ([]byte, error)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	resp, err := fs.openFile(path)
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(resp.Content)
+	resp.Content.Close()
+
+	if err != nil {
+		return err
+	}
+
+	rewritten, err := fn(content)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(content, rewritten) {
+		return nil
+	}
+
+	refs, _, err := readRefs(path)
+	if err != nil {
+		return err
+	}
+
+	sum, err := fs.blobs.Put(bytes.NewReader(rewritten))
+	if err != nil {
+		return err
+	}
+
+	if err := fs.replaceFile(path, encodeRefs(ref{sum: sum, size: int64(len(content))}), info.ModTime()); err != nil {
+		if uerr := fs.blobs.Unref(sum); uerr != nil {
+			return fmt.Errorf("%w (and failed to release blob: %v)", err, uerr)
+		}
+
+		return err
+	}
+
+	// the blobs the file pointed at before aren't needed by it anymore.
+	for _, old := range refs {
+		if err := fs.blobs.Unref(old.sum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceFile replaces the file at path with content, keeping its modified
+// date.
+func (fs *Filesystem) replaceFile(path string, content []byte, modified time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := fs.writeTemp(tmp, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(tmp.Name(), modified, modified); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (fs *Filesystem) metadataPath(key string) string {
+	return filepath.Join(fs.path, key+metadataSuffix)
+}
+
+func (fs *Filesystem) revisionsPath(key string) string {
+	return filepath.Join(fs.path, key+revisionsSuffix)
+}
+
+// readMetadata loads the metadata for key. Jots written before metadata
+// existed have no file, and get empty metadata.
+func (fs *Filesystem) readMetadata(key string) (store.Metadata, error) {
+	var meta store.Metadata
+
+	b, err := os.ReadFile(fs.metadataPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+
+		return meta, err
+	}
+
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, err
+	}
+
+	return meta, nil
 }
 
-func NewFilesystem(opts FilesystemOptions) (*Filesystem, error) {
+func NewFilesystem(opts FilesystemOptions, blobs *blob.Store) (*Filesystem, error) {
 	if err := os.MkdirAll(opts.Path, opts.DirectoryPermissions); err != nil {
 		return nil, err
 	}
@@ -113,5 +659,6 @@ func NewFilesystem(opts FilesystemOptions) (*Filesystem, error) {
 		path:                 opts.Path,
 		filePermissions:      opts.FilePermissions,
 		directoryPermissions: opts.DirectoryPermissions,
+		blobs:                blobs,
 	}, nil
 }
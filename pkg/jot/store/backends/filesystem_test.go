@@ -2,11 +2,22 @@ package backends_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/compression"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/jot/store"
+	"github.com/kyleterry/jot/pkg/jot/store/backends"
 	"github.com/kyleterry/jot/pkg/testutil"
+	"github.com/kyleterry/jot/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -81,3 +92,247 @@ func TestFilesystemDelete(t *testing.T) {
 	_, err := os.Stat(filepath.Join(tmpdir, key))
 	require.True(t, os.IsNotExist(err))
 }
+
+func TestFilesystemMetadata(t *testing.T) {
+	tmpdir, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	key := "abc123"
+	expires := time.Now().Add(time.Hour).Round(0)
+
+	require.NoError(t, fs.PutMetadata(key, store.Metadata{ExpiresAt: expires}))
+	require.NoError(t, fs.Put(key, &NoopCloseBuffer{bytes.NewBufferString("test payload")}))
+
+	r, err := fs.Stat(key)
+	require.NoError(t, err)
+	require.True(t, expires.Equal(r.Metadata.ExpiresAt))
+
+	keys, err := fs.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{key}, keys)
+
+	require.NoError(t, fs.Delete(key))
+
+	_, err = os.Stat(filepath.Join(tmpdir, key+".meta"))
+	require.True(t, os.IsNotExist(err))
+
+	// metadata whose content was never written is deleted too
+	require.NoError(t, fs.PutMetadata(key, store.Metadata{ExpiresAt: expires}))
+	require.True(t, os.IsNotExist(fs.Delete(key)))
+
+	_, err = os.Stat(filepath.Join(tmpdir, key+".meta"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestFilesystemRevisions(t *testing.T) {
+	_, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	key := "abc123"
+
+	require.NoError(t, fs.Put(key, &NoopCloseBuffer{bytes.NewBufferString("first")}))
+	require.NoError(t, fs.Put(key, &NoopCloseBuffer{bytes.NewBufferString("second")}))
+
+	revisions, err := fs.Revisions(key)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, 1, revisions[0].Number)
+	require.Equal(t, int64(len("first")), revisions[0].Size)
+	require.Equal(t, 2, revisions[1].Number)
+
+	r, err := fs.GetRevision(key, 1)
+	require.NoError(t, err)
+
+	responsebuf := &bytes.Buffer{}
+	responsebuf.ReadFrom(r.Content)
+	r.Content.Close()
+
+	require.Equal(t, "first", responsebuf.String())
+
+	_, err = fs.GetRevision(key, 3)
+	require.Error(t, err)
+
+	keys, err := fs.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{key}, keys)
+}
+
+func TestFilesystemAppend(t *testing.T) {
+	_, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	key := "abc123"
+
+	require.NoError(t, fs.Put(key, &NoopCloseBuffer{bytes.NewBufferString("first\n")}))
+	require.NoError(t, fs.Append(key, &NoopCloseBuffer{bytes.NewBufferString("second\n")}))
+
+	r, err := fs.Get(key)
+	require.NoError(t, err)
+
+	responsebuf := &bytes.Buffer{}
+	responsebuf.ReadFrom(r.Content)
+	r.Content.Close()
+
+	require.Equal(t, "first\nsecond\n", responsebuf.String())
+
+	revisions, err := fs.Revisions(key)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+
+	require.Error(t, fs.Append("missing", &NoopCloseBuffer{bytes.NewBufferString("nope")}))
+}
+
+func TestFilesystemCompression(t *testing.T) {
+	tmpdir, _, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	blobs, err := blob.New(&blob.Options{StorageDir: config.DataDir(tmpdir), Compression: "gzip"})
+	require.NoError(t, err)
+
+	fs, err := backends.NewFilesystem(backends.FilesystemOptions{
+		Path:                 tmpdir,
+		FilePermissions:      config.FilePermissions,
+		DirectoryPermissions: config.DirectoryPermissions,
+	}, blobs)
+	require.NoError(t, err)
+
+	key := "abc123"
+	old := "written before blobs\n"
+	payload := strings.Repeat("a line of a log file\n", 100)
+
+	// jots written before blobs existed hold their content, which may be
+	// compressed.
+	var legacy bytes.Buffer
+	require.NoError(t, compression.Encode(&legacy, strings.NewReader(old), compression.Gzip))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, key), legacy.Bytes(), config.FilePermissions))
+	require.NoError(t, fs.Append(key, &NoopCloseBuffer{bytes.NewBufferString("appended\n")}))
+
+	require.NoError(t, fs.Put(key, &NoopCloseBuffer{bytes.NewBufferString(payload)}))
+
+	r, err := fs.Get(key)
+	require.NoError(t, err)
+
+	encoded, ok := r.Content.(types.EncodedContent)
+	require.True(t, ok)
+
+	raw, ok := encoded.Encoded("gzip")
+	require.True(t, ok)
+
+	stored, err := io.ReadAll(raw)
+	require.NoError(t, err)
+	require.Less(t, len(stored), len(payload))
+
+	content, err := io.ReadAll(r.Content)
+	r.Content.Close()
+	require.NoError(t, err)
+	require.Equal(t, payload, string(content))
+
+	revisions, err := fs.Revisions(key)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	// the size of content written before sizes were recorded isn't known
+	// without reading it, the size of content written since is.
+	require.Equal(t, int64(-1), revisions[0].Size)
+	require.Equal(t, int64(len(payload)), revisions[1].Size)
+
+	r, err = fs.GetRevision(key, 1)
+	require.NoError(t, err)
+
+	content, err = io.ReadAll(r.Content)
+	r.Content.Close()
+	require.NoError(t, err)
+	require.Equal(t, old+"appended\n", string(content))
+}
+
+func TestFilesystemBlobs(t *testing.T) {
+	tmpdir, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	blobs, err := blob.New(&blob.Options{StorageDir: config.DataDir(tmpdir)})
+	require.NoError(t, err)
+
+	payload := "the same large log"
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(payload)))
+
+	refs := func() int {
+		n, err := blobs.Refs(sum)
+		require.NoError(t, err)
+
+		return n
+	}
+
+	// identical jots are stored once
+	require.NoError(t, fs.Put("one", &NoopCloseBuffer{bytes.NewBufferString(payload)}))
+	require.NoError(t, fs.Put("two", &NoopCloseBuffer{bytes.NewBufferString(payload)}))
+	require.Equal(t, 2, refs())
+
+	// revisions point at the blob too
+	require.NoError(t, fs.Put("two", &NoopCloseBuffer{bytes.NewBufferString("changed")}))
+	require.Equal(t, 2, refs())
+	require.NoError(t, fs.Put("two", &NoopCloseBuffer{bytes.NewBufferString("changed again")}))
+	require.Equal(t, 2, refs())
+
+	// content appended to a small blob goes into a new blob that holds both,
+	// so only the revision of two points at the shared one now
+	require.NoError(t, fs.Append("one", &NoopCloseBuffer{bytes.NewBufferString(payload)}))
+	require.Equal(t, 1, refs())
+
+	r, err := fs.Get("one")
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r.Content)
+	r.Content.Close()
+	require.NoError(t, err)
+	require.Equal(t, payload+payload, string(content))
+
+	revisions, err := fs.Revisions("one")
+	require.NoError(t, err)
+	require.Equal(t, int64(2*len(payload)), revisions[0].Size)
+
+	require.NoError(t, fs.Delete("one"))
+	require.Equal(t, 1, refs())
+
+	r, err = fs.Take("two")
+	require.NoError(t, err)
+	require.Zero(t, refs())
+
+	// the blob is gone, but what was taken can still be read
+	content, err = io.ReadAll(r.Content)
+	r.Content.Close()
+	require.NoError(t, err)
+	require.Equal(t, "changed again", string(content))
+
+	entries, err := filepath.Glob(filepath.Join(tmpdir, config.BlobDirectoryName, "*", "*"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.Error(t, fs.Delete("one"))
+}
+
+func TestFilesystemAppendCoalesces(t *testing.T) {
+	tmpdir, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	require.NoError(t, fs.Put("abc", &NoopCloseBuffer{bytes.NewBufferString("start\n")}))
+
+	for range 100 {
+		require.NoError(t, fs.Append("abc", &NoopCloseBuffer{bytes.NewBufferString("line\n")}))
+	}
+
+	r, err := fs.Get("abc")
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r.Content)
+	r.Content.Close()
+	require.NoError(t, err)
+	require.Equal(t, "start\n"+strings.Repeat("line\n", 100), string(content))
+
+	revisions, err := fs.Revisions("abc")
+	require.NoError(t, err)
+	require.EqualValues(t, len(content), revisions[0].Size)
+
+	// the small appends share a blob, and the blobs they replaced are gone
+	blobs, err := filepath.Glob(filepath.Join(tmpdir, config.BlobDirectoryName, "*", "*.refs"))
+	require.NoError(t, err)
+	require.Len(t, blobs, 1)
+}
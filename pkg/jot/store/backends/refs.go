@@ -0,0 +1,251 @@
+package backends
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/compression"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/jot/store"
+)
+
+// refsHeader starts the files of jots and revisions that point at blobs. It
+// isn't valid UTF-8 text, so jots written before blobs existed, whose files
+// hold their content, are never mistaken for them. The header is followed by
+// one line per blob with its sum and the size of the content it holds, and
+// the content is the blobs one after the other. Files written before sizes
+// were recorded only have the sums.
+const refsHeader = "\x00jotrefs\n"
+
+// ref is a blob a jot or revision points at. size is the size of the content
+// it holds, or -1 if it wasn't recorded.
+type ref struct {
+	sum  string
+	size int64
+}
+
+func (r ref) line() string {
+	if r.size < 0 {
+		return r.sum + "\n"
+	}
+
+	return r.sum + " " + strconv.FormatInt(r.size, 10) + "\n"
+}
+
+// encodeRefs returns the content of a file that points at refs.
+func encodeRefs(refs ...ref) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(refsHeader)
+
+	for _, r := range refs {
+		buf.WriteString(r.line())
+	}
+
+	return buf.Bytes()
+}
+
+// readRefs returns the blobs the file at path points at. ok is false for files
+// that hold their content.
+func readRefs(path string) (refs []ref, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	head, _ := r.Peek(len(refsHeader))
+	if string(head) != refsHeader {
+		return nil, false, nil
+	}
+
+	r.Discard(len(refsHeader))
+
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		malformed := errors.NewUnknownError(fmt.Sprintf("malformed blob reference in %s", path))
+
+		sum, size, sized := strings.Cut(strings.TrimSpace(line), " ")
+		if !blob.ValidSum(sum) {
+			return nil, false, malformed
+		}
+
+		rf := ref{sum: sum, size: -1}
+
+		if sized {
+			if rf.size, err = strconv.ParseInt(size, 10, 64); err != nil || rf.size < 0 {
+				return nil, false, malformed
+			}
+		}
+
+		refs = append(refs, rf)
+	}
+
+	return refs, true, nil
+}
+
+// sums returns the sums of the blobs refs point at.
+func sums(refs []ref) []string {
+	sums := make([]string, len(refs))
+	for i, r := range refs {
+		sums[i] = r.sum
+	}
+
+	return sums
+}
+
+// appendBlobSize is the size up to which content appended to a jot goes into
+// the last blob it points at instead of a blob of its own.
+const appendBlobSize = 1 << 20
+
+// putBlob stores content as a blob and returns a reference to it. Content
+// that is stored as something other than what it holds, such as sealed
+// content, reports the size of what it holds itself.
+func (fs *Filesystem) putBlob(content io.Reader) (ref, error) {
+	counter := &countingReader{r: content}
+
+	sum, err := fs.blobs.Put(counter)
+	if err != nil {
+		return ref{}, err
+	}
+
+	return ref{sum: sum, size: contentSize(content, counter.n)}, nil
+}
+
+// extendBlob stores the content of the blob r points at followed by content
+// as a new blob and returns a reference to it. The blob r points at is left
+// as it is.
+func (fs *Filesystem) extendBlob(r ref, content io.Reader) (ref, error) {
+	prev, err := fs.blobs.Open(r.sum)
+	if err != nil {
+		return ref{}, err
+	}
+
+	defer prev.Close()
+
+	counter := &countingReader{r: content}
+
+	sum, err := fs.blobs.Put(io.MultiReader(prev, counter))
+	if err != nil {
+		return ref{}, err
+	}
+
+	return ref{sum: sum, size: r.size + contentSize(content, counter.n)}, nil
+}
+
+// contentSize returns the size of what content holds, n bytes of which were
+// read from it.
+func contentSize(content io.Reader, n int64) int64 {
+	if sizer, ok := content.(store.Sizer); ok {
+		return sizer.Size()
+	}
+
+	return n
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+// openFile returns the content of the jot or revision file at path.
+func (fs *Filesystem) openFile(path string) (*store.GetResponse, error) {
+	refs, ok, err := readRefs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		content, err := fs.blobs.OpenAll(sums(refs))
+		if err != nil {
+			return nil, err
+		}
+
+		return &store.GetResponse{Content: content}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := compression.Open(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.GetResponse{Content: content}, nil
+}
+
+// size returns the size of the content of the jot or revision file at path as
+// it was recorded when the content was written. It's -1 for files written
+// before sizes were recorded, whose content has to be read to tell.
+func (fs *Filesystem) size(path string) (int64, error) {
+	refs, ok, err := readRefs(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return -1, nil
+	}
+
+	var size int64
+
+	for _, r := range refs {
+		if r.size < 0 {
+			return -1, nil
+		}
+
+		size += r.size
+	}
+
+	return size, nil
+}
+
+// release removes the references of the file at path from the blobs it
+// points at, before the file is removed. Files that are already gone have
+// nothing to release.
+func (fs *Filesystem) release(path string) error {
+	refs, _, err := readRefs(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, r := range refs {
+		if err := fs.blobs.Unref(r.sum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -11,11 +11,57 @@ type GetResponse struct {
 
 type StatResponse struct {
 	ModifiedDate time.Time
+	Metadata     Metadata
+}
+
+// Revision describes one version of a jot's content. Size is -1 when the
+// backend can't tell without reading the content.
+type Revision struct {
+	Number       int
+	ModifiedDate time.Time
+	Size         int64
+}
+
+// Metadata is stored alongside the content of a jot.
+type Metadata struct {
+	ExpiresAt        time.Time `json:"expires_at,omitzero"`
+	BurnAfterReading bool      `json:"burn_after_reading,omitempty"`
+	ContentType      string    `json:"content_type,omitempty"`
+	Filename         string    `json:"filename,omitempty"`
+	Encrypted        bool      `json:"encrypted,omitempty"`
+	ForkOf           string    `json:"fork_of,omitempty"`
 }
 
 type Backend interface {
 	Stat(key string) (*StatResponse, error)
 	Get(key string) (*GetResponse, error)
+	// Take removes a jot and returns its content in one atomic step. When
+	// several callers take the same key at once only one of them gets the
+	// content; the rest get a not found error.
+	Take(key string) (*GetResponse, error)
+	// Put writes content under key. If the key already exists, its current
+	// content is kept as a revision.
 	Put(key string, content io.ReadCloser) error
+	PutMetadata(key string, meta Metadata) error
 	Delete(key string) error
+	// Revisions returns every revision of a jot, oldest first. The last one is
+	// the current content.
+	Revisions(key string) ([]Revision, error)
+	GetRevision(key string, number int) (*GetResponse, error)
+	// List returns the keys of every jot in the backend.
+	List() ([]string, error)
+}
+
+// Appender is implemented by backends that can add content to the end of a jot
+// without rewriting it. Appending doesn't create a revision.
+type Appender interface {
+	Append(key string, content io.ReadCloser) error
+}
+
+// Sizer is implemented by content that is stored as something other than
+// what it holds, such as sealed content. Once the content has been read, Size
+// returns the size of what it holds, which backends record instead of the
+// size of what they stored.
+type Sizer interface {
+	Size() int64
 }
@@ -0,0 +1,91 @@
+// Package markdown renders GitHub flavored markdown to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// AnchorClass is the class of the links added to headings.
+const AnchorClass = "anchor"
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		// fenced code blocks use the same classes as the highlight package
+		highlighting.NewHighlighting(
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(headingAnchors{}, 100)),
+	),
+)
+
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\w-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("a", "pre", "code", "span")
+	p.AllowAttrs("checked", "disabled", "type").OnElements("input")
+
+	return p
+}()
+
+// Render converts markdown source to HTML that is safe to embed in a page.
+func Render(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := md.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+
+	return policy.SanitizeBytes(buf.Bytes()), nil
+}
+
+// headingAnchors adds a link to the start of every heading that points at the
+// heading itself, so sections can be linked to.
+type headingAnchors struct{}
+
+func (headingAnchors) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		idBytes, ok := id.([]byte)
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		link := ast.NewLink()
+		link.Destination = append([]byte("#"), idBytes...)
+		link.SetAttributeString("class", []byte(AnchorClass))
+		link.AppendChild(link, ast.NewString([]byte("#")))
+
+		heading.InsertBefore(heading, heading.FirstChild(), link)
+
+		return ast.WalkSkipChildren, nil
+	})
+}
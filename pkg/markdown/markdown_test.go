@@ -0,0 +1,27 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/markdown"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	source := "# Deploy Runbook\n\n" +
+		"| step | command |\n|---|---|\n| 1 | `make` |\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"<script>alert(1)</script>\n\n" +
+		"[click](javascript:alert(1))\n"
+
+	out, err := markdown.Render([]byte(source))
+	require.NoError(t, err)
+
+	html := string(out)
+
+	require.Contains(t, html, `<h1 id="deploy-runbook"><a href="#deploy-runbook" class="anchor"`)
+	require.Contains(t, html, "<table>")
+	require.Contains(t, html, `<span class="kd">func</span>`)
+	require.NotContains(t, html, "<script>")
+	require.NotContains(t, html, "javascript:")
+}
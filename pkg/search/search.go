@@ -0,0 +1,581 @@
+// Package search keeps an inverted index of the words in jots so they can be
+// found again by their content. The index only holds words and dates; the
+// lines that match are read from the jots themselves.
+package search
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kyleterry/jot/pkg/config"
+	jotstore "github.com/kyleterry/jot/pkg/jot/store"
+)
+
+// IndexFileName is the name of the file the index is kept in, in the data
+// dir.
+const IndexFileName = "search.json"
+
+// minLogged is how many changes the log of an index holds at least before
+// they're folded into the index file. Above it the log is folded once it
+// holds more changes than the index holds jots, so saving stays cheap for
+// indexes of any size.
+const minLogged = 1000
+
+// maxTokenLength is the longest word that's indexed. Longer runs of letters
+// are mostly encoded data nobody searches for.
+const maxTokenLength = 64
+
+// Index maps the words in jots to their keys. The zero value isn't usable;
+// use New or Open. Methods on a nil Index do nothing, so stores without
+// search don't have to check for one.
+type Index struct {
+	mu sync.RWMutex
+	// path is where the index is saved. Indexes without one only live in
+	// memory.
+	path     string
+	docs     map[string]document
+	postings map[string]map[string]struct{}
+	// logged counts the changes in the log since the index file was saved.
+	logged int
+}
+
+// document is what the index knows about one jot.
+type document struct {
+	ModifiedDate time.Time `json:"modified_date"`
+	Tokens       []string  `json:"tokens"`
+}
+
+// change is a line of the log of an index: the document of the jot under
+// Key, or nil if it was removed. Changes hold whole documents, so replaying
+// one twice does no harm.
+type change struct {
+	Key string    `json:"key"`
+	Doc *document `json:"doc"`
+}
+
+// Hit is a jot that matches a query.
+type Hit struct {
+	Key          string
+	ModifiedDate time.Time
+}
+
+// Snippet is a line of a jot that matches a query. Lines are numbered from 1.
+type Snippet struct {
+	Line int
+	Text string
+}
+
+// Add indexes the content of the jot under key, replacing whatever was indexed
+// for it before.
+func (idx *Index) Add(key string, modified time.Time, content io.Reader) error {
+	if idx == nil {
+		return nil
+	}
+
+	tokens, err := tokenize(content)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	doc := document{ModifiedDate: modified, Tokens: tokens}
+
+	idx.remove(key)
+	idx.add(key, doc)
+
+	return idx.log(change{Key: key, Doc: &doc})
+}
+
+// Extend indexes content that was appended to the jot under key.
+func (idx *Index) Extend(key string, modified time.Time, content io.Reader) error {
+	if idx == nil {
+		return nil
+	}
+
+	tokens, err := tokenize(content)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	doc := idx.docs[key]
+	idx.remove(key)
+
+	doc.ModifiedDate = modified
+	doc.Tokens = union(doc.Tokens, tokens)
+	idx.add(key, doc)
+
+	return idx.log(change{Key: key, Doc: &doc})
+}
+
+// Remove drops the jot under key from the index.
+func (idx *Index) Remove(key string) error {
+	if idx == nil {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.docs[key]; !ok {
+		return nil
+	}
+
+	idx.remove(key)
+
+	return idx.log(change{Key: key})
+}
+
+// Search returns the jots that contain every word in query, most recently
+// modified first.
+func (idx *Index) Search(query string) []Hit {
+	if idx == nil {
+		return nil
+	}
+
+	tokens := Tokens(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var hits []Hit
+
+	for key := range idx.postings[tokens[0]] {
+		matches := true
+
+		for _, token := range tokens[1:] {
+			if _, ok := idx.postings[token][key]; !ok {
+				matches = false
+
+				break
+			}
+		}
+
+		if matches {
+			hits = append(hits, Hit{Key: key, ModifiedDate: idx.docs[key].ModifiedDate})
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := b.ModifiedDate.Compare(a.ModifiedDate); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return hits
+}
+
+// Len returns the number of jots in the index.
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+func (idx *Index) add(key string, doc document) {
+	idx.docs[key] = doc
+
+	for _, token := range doc.Tokens {
+		if idx.postings[token] == nil {
+			idx.postings[token] = make(map[string]struct{})
+		}
+
+		idx.postings[token][key] = struct{}{}
+	}
+}
+
+func (idx *Index) remove(key string) {
+	for _, token := range idx.docs[key].Tokens {
+		delete(idx.postings[token], key)
+
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+
+	delete(idx.docs, key)
+}
+
+// log appends a change to the log next to the index file, so saving it
+// doesn't write the whole index. The log is folded into the index file once
+// it's grown too long.
+func (idx *Index) log(c change) error {
+	if idx.path == "" {
+		return nil
+	}
+
+	if idx.logged >= max(len(idx.docs), minLogged) {
+		return idx.save()
+	}
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(logPath(idx.path), os.O_WRONLY|os.O_APPEND|os.O_CREATE, config.FilePermissions)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	idx.logged++
+
+	return nil
+}
+
+// save writes the index next to its file and renames it over it, so a crash
+// never leaves a half written index behind. The log is emptied after, since
+// the index file holds its changes; a crash in between only means they're
+// replayed again.
+func (idx *Index) save() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(idx.docs)
+	if err != nil {
+		return err
+	}
+
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, config.FilePermissions); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return err
+	}
+
+	if err := os.Remove(logPath(idx.path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	idx.logged = 0
+
+	return nil
+}
+
+// replay applies the changes in the log of the index and reports whether
+// there is one. A crash while a change was written leaves a broken last line,
+// which ends the log; the index is saved then, so nothing is appended after
+// it.
+func (idx *Index) replay() (bool, error) {
+	f, err := os.Open(logPath(idx.path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+
+	for scanner.Scan() {
+		var c change
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return true, idx.save()
+		}
+
+		idx.remove(c.Key)
+
+		if c.Doc != nil {
+			idx.add(c.Key, *c.Doc)
+		}
+
+		idx.logged++
+	}
+
+	return true, scanner.Err()
+}
+
+// logPath returns where the log of the index at path is kept.
+func logPath(path string) string {
+	return path + ".log"
+}
+
+// Snippets returns up to max lines of content that contain a word in query,
+// each cut to a readable length.
+func Snippets(content io.Reader, query string, max int) ([]Snippet, error) {
+	tokens := Tokens(query)
+
+	var snippets []Snippet
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for n := 1; scanner.Scan() && len(snippets) < max; n++ {
+		line := scanner.Text()
+
+		lineTokens := Tokens(line)
+		if !slices.ContainsFunc(tokens, func(token string) bool {
+			return slices.Contains(lineTokens, token)
+		}) {
+			continue
+		}
+
+		snippets = append(snippets, Snippet{Line: n, Text: truncate(strings.TrimSpace(line), 200)})
+	}
+
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Tokens returns the distinct words in s, folded to lower case.
+func Tokens(s string) []string {
+	var tokens []string
+
+	for _, field := range strings.FieldsFunc(s, isSeparator) {
+		token := strings.ToLower(field)
+		if len(token) > maxTokenLength || slices.Contains(tokens, token) {
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+func tokenize(content io.Reader) ([]string, error) {
+	seen := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	scanner.Split(scanWords)
+
+	for scanner.Scan() {
+		token := strings.ToLower(scanner.Text())
+		if len(token) <= maxTokenLength {
+			seen[token] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, 0, len(seen))
+	for token := range seen {
+		tokens = append(tokens, token)
+	}
+
+	slices.Sort(tokens)
+
+	return tokens, nil
+}
+
+// scanWords is a bufio.SplitFunc that returns runs of letters and digits.
+func scanWords(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+
+	for start < len(data) {
+		if !atEOF && !utf8.FullRune(data[start:]) {
+			return start, nil, nil
+		}
+
+		r, width := utf8.DecodeRune(data[start:])
+		if !isSeparator(r) {
+			break
+		}
+
+		start += width
+	}
+
+	for i := start; i < len(data); {
+		if !atEOF && !utf8.FullRune(data[i:]) {
+			return start, nil, nil
+		}
+
+		r, width := utf8.DecodeRune(data[i:])
+		if isSeparator(r) {
+			return i + width, data[start:i], nil
+		}
+
+		i += width
+	}
+
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+
+	// runs too long to be a word are skipped rather than buffered.
+	if len(data)-start > 4*maxTokenLength {
+		return len(data), nil, nil
+	}
+
+	return start, nil, nil
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func union(a, b []string) []string {
+	out := slices.Clone(a)
+
+	for _, token := range b {
+		if !slices.Contains(a, token) {
+			out = append(out, token)
+		}
+	}
+
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n]) + "…"
+}
+
+// New returns an empty index that's saved to path. An empty path keeps the
+// index in memory only.
+func New(path string) *Index {
+	return &Index{
+		path:     path,
+		docs:     make(map[string]document),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// Open loads the index saved at path. ok is false when there's no index there
+// yet, in which case an empty one is returned that still saves to path.
+func Open(path string) (idx *Index, ok bool, err error) {
+	idx = New(path)
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	saved := err == nil
+
+	if saved {
+		var docs map[string]document
+		if err := json.Unmarshal(raw, &docs); err != nil {
+			return nil, false, err
+		}
+
+		for key, doc := range docs {
+			idx.add(key, doc)
+		}
+	}
+
+	// an index that was never saved whole may still have a log.
+	logged, err := idx.replay()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return idx, saved || logged, nil
+}
+
+// Delete removes the index saved at path, along with its log.
+func Delete(path string) error {
+	for _, p := range []string{path, logPath(path)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Indexable reports whether a jot with meta belongs in the index. Jots that
+// burn after reading must not be read by anyone else, and jots encrypted by
+// the client can't be read by the server.
+func Indexable(meta jotstore.Metadata) bool {
+	return !meta.BurnAfterReading && !meta.Encrypted
+}
+
+// Rebuild replaces the index with every indexable jot in backend.
+func (idx *Index) Rebuild(backend jotstore.Backend) error {
+	keys, err := backend.List()
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	clear(idx.docs)
+	clear(idx.postings)
+
+	for _, key := range keys {
+		stat, err := backend.Stat(key)
+		if err != nil {
+			// the jot was most likely deleted since it was listed
+			continue
+		}
+
+		if !Indexable(stat.Metadata) {
+			continue
+		}
+
+		resp, err := backend.Get(key)
+		if err != nil {
+			continue
+		}
+
+		tokens, err := tokenize(resp.Content)
+		resp.Content.Close()
+
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", key, err)
+		}
+
+		idx.add(key, document{ModifiedDate: stat.ModifiedDate, Tokens: tokens})
+	}
+
+	return idx.save()
+}
+
+// IndexPath returns where the index is kept in the data dir.
+func IndexPath(dir config.DataDir) string {
+	return filepath.Join(string(dir), IndexFileName)
+}
@@ -0,0 +1,155 @@
+package search_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/jot/store"
+	"github.com/kyleterry/jot/pkg/search"
+	"github.com/kyleterry/jot/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func keys(hits []search.Hit) []string {
+	var keys []string
+	for _, hit := range hits {
+		keys = append(keys, hit.Key)
+	}
+
+	return keys
+}
+
+func TestIndex(t *testing.T) {
+	idx := search.New("")
+	now := time.Now()
+
+	require.NoError(t, idx.Add("a", now, strings.NewReader("Hello, World!\nfoo_bar")))
+	require.NoError(t, idx.Add("b", now.Add(time.Second), strings.NewReader("hello there")))
+
+	require.Equal(t, []string{"b", "a"}, keys(idx.Search("HELLO")))
+	require.Equal(t, []string{"a"}, keys(idx.Search("hello world")))
+	require.Equal(t, []string{"a"}, keys(idx.Search("bar")))
+	require.Empty(t, idx.Search("hello nobody"))
+	require.Empty(t, idx.Search("  ,. "))
+
+	require.NoError(t, idx.Extend("a", now.Add(2*time.Second), strings.NewReader("general kenobi")))
+	require.Equal(t, []string{"a", "b"}, keys(idx.Search("hello")))
+	require.Equal(t, []string{"a"}, keys(idx.Search("kenobi world")))
+
+	require.NoError(t, idx.Add("a", now, strings.NewReader("replaced")))
+	require.Empty(t, idx.Search("kenobi"))
+
+	require.NoError(t, idx.Remove("a"))
+	require.Empty(t, idx.Search("replaced"))
+	require.Equal(t, 1, idx.Len())
+
+	var nilIndex *search.Index
+	require.NoError(t, nilIndex.Add("a", now, strings.NewReader("hello")))
+	require.Empty(t, nilIndex.Search("hello"))
+}
+
+func TestIndexPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), search.IndexFileName)
+
+	idx, ok, err := search.Open(path)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, idx.Add("a", time.Now(), strings.NewReader("saved to disk")))
+
+	idx, ok, err = search.Open(path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"a"}, keys(idx.Search("disk")))
+}
+
+func TestIndexLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), search.IndexFileName)
+	now := time.Now()
+
+	idx, _, err := search.Open(path)
+	require.NoError(t, err)
+
+	// changes are appended to the log instead of saving the whole index.
+	require.NoError(t, idx.Add("a", now, strings.NewReader("first words")))
+	require.NoError(t, idx.Extend("a", now, strings.NewReader("more words")))
+	require.NoError(t, idx.Add("b", now, strings.NewReader("other words")))
+	require.NoError(t, idx.Remove("b"))
+	require.NoFileExists(t, path)
+
+	// a change cut short by a crash is dropped.
+	f, err := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"key":"c","doc":{"tok`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	idx, ok, err := search.Open(path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"a"}, keys(idx.Search("first more")))
+	require.Empty(t, idx.Search("other"))
+	require.Equal(t, 1, idx.Len())
+
+	// the log is folded into the index once it's long.
+	for i := range 1000 {
+		require.NoError(t, idx.Add("a", now, strings.NewReader(fmt.Sprintf("word%d", i))))
+	}
+
+	require.FileExists(t, path+".log")
+	require.NoError(t, idx.Add("a", now, strings.NewReader("last word")))
+	require.NoFileExists(t, path+".log")
+
+	idx, _, err = search.Open(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, keys(idx.Search("last")))
+	require.Empty(t, idx.Search("word999"))
+
+	require.NoError(t, search.Delete(path))
+
+	_, ok, err = search.Open(path)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.NoFileExists(t, path+".log")
+}
+
+func TestIndexRebuild(t *testing.T) {
+	_, fs, cleanup := testutil.NewTextFilesystem(t)
+	defer cleanup()
+
+	put := func(key, content string, meta store.Metadata) {
+		require.NoError(t, fs.PutMetadata(key, meta))
+		require.NoError(t, fs.Put(key, io.NopCloser(strings.NewReader(content))))
+	}
+
+	put("plain", "find me", store.Metadata{})
+	put("burn", "find me", store.Metadata{BurnAfterReading: true})
+	put("encrypted", "find me", store.Metadata{Encrypted: true})
+
+	idx := search.New("")
+	require.NoError(t, idx.Add("stale", time.Now(), strings.NewReader("find me")))
+
+	require.NoError(t, idx.Rebuild(fs))
+	require.Equal(t, []string{"plain"}, keys(idx.Search("find")))
+}
+
+func TestSnippets(t *testing.T) {
+	content := "first line\nsecond Needle\nthird\n  needle again  \n" + strings.Repeat("x", 300) + " needle\n"
+
+	snippets, err := search.Snippets(strings.NewReader(content), "needle", 2)
+	require.NoError(t, err)
+	require.Equal(t, []search.Snippet{
+		{Line: 2, Text: "second Needle"},
+		{Line: 4, Text: "needle again"},
+	}, snippets)
+
+	snippets, err = search.Snippets(strings.NewReader(content), "needle", 5)
+	require.NoError(t, err)
+	require.Len(t, snippets, 3)
+	require.Equal(t, strings.Repeat("x", 200)+"…", snippets[2].Text)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/search"
+	"github.com/kyleterry/jot/pkg/text"
+)
+
+const (
+	// maxSearchResults is the most jots a search returns.
+	maxSearchResults = 50
+	// maxSearchSnippets is the most matching lines shown for each jot.
+	maxSearchSnippets = 3
+)
+
+// adminHandler handles requests under /admin. Every request must carry the
+// admin password, and the whole tree is hidden when none is configured.
+type adminHandler struct {
+	cfg           *config.Config
+	store         text.StoreService
+	index         *search.Index
+	searchHandler http.Handler
+}
+
+func (h adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var handler http.Handler
+
+	head, _ := shiftPath(r.URL.Path)
+
+	switch head {
+	case "search":
+		if r.Method == http.MethodGet {
+			handler = h.searchHandler
+		}
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if handler == nil {
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// search writes the jots that contain every word in the q query parameter,
+// most recently modified first. Each jot is a line with its URL and modified
+// date, followed by its matching lines indented under it.
+func (h adminHandler) search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query().Get("q")
+	if len(search.Tokens(query)) == 0 {
+		WriteError(errors.NewBadRequestError("a search query is required"), w)
+
+		return
+	}
+
+	u, err := url.Parse(extractHost(h.cfg, r))
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	w.Header().Set("content-type", DefaultContentType)
+
+	results := 0
+
+	for _, hit := range h.index.Search(query) {
+		if results == maxSearchResults {
+			break
+		}
+
+		// the index can lag behind jots that expired or changed since they
+		// were indexed, so the store has the final say.
+		jotFile, err := h.store.Stat(ctx, hit.Key)
+		if err != nil || jotFile.BurnAfterReading || jotFile.Encrypted {
+			continue
+		}
+
+		jotFile, err = h.store.Get(ctx, hit.Key)
+		if err != nil {
+			continue
+		}
+
+		snippets, err := search.Snippets(jotFile.Content, query, maxSearchSnippets)
+		jotFile.Content.Close()
+
+		if err != nil {
+			log.Println(fmt.Errorf("error while reading search snippets: %w", err))
+
+			continue
+		}
+
+		results++
+
+		if _, err := fmt.Fprintf(w, "%s %s\n", u.JoinPath("txt", hit.Key), jotFile.ModifiedDate.Format(time.RFC3339Nano)); err != nil {
+			log.Println(fmt.Errorf("error while writing search results: %w", err))
+
+			return
+		}
+
+		for _, snippet := range snippets {
+			if _, err := fmt.Fprintf(w, "  %d: %s\n", snippet.Line, snippet.Text); err != nil {
+				log.Println(fmt.Errorf("error while writing search results: %w", err))
+
+				return
+			}
+		}
+	}
+}
+
+// withAdminMiddleware hides the admin routes when no admin password is
+// configured and otherwise requires it via HTTP Basic Auth. The username
+// field is ignored.
+func withAdminMiddleware(cfg *config.Config) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.AdminPassword == "" {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			_, pw, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(pw), []byte(cfg.AdminPassword)) != 1 {
+				WriteError(errors.NewInvalidPasswordError(), w)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func NewAdminHandler(cfg *config.Config, store text.StoreService, index *search.Index) *adminHandler {
+	h := &adminHandler{
+		cfg:   cfg,
+		store: store,
+		index: index,
+	}
+
+	admin := NewMiddleware(withAdminMiddleware(cfg))
+
+	h.searchHandler = admin.Wrap(http.HandlerFunc((*h).search))
+
+	return h
+}
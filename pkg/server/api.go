@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+// apiVersion is the version of the API served under /api/<version>.
+const apiVersion = "v1"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiRoute is an operation of the API. Patterns are relative to /api/v1 and
+// name path parameters like the OpenAPI spec does, as in /txt/{key}.
+type apiRoute struct {
+	method  string
+	pattern string
+	handler http.Handler
+}
+
+// match reports whether path matches the pattern of the route. Parameters
+// match any one path segment.
+func (route apiRoute) match(path string) bool {
+	want := strings.Split(strings.Trim(route.pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+
+	if len(want) != len(got) {
+		return false
+	}
+
+	for i := range want {
+		if strings.HasPrefix(want[i], "{") || want[i] == got[i] {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// base64Encoding is the content_encoding of jot content that is sent as
+// base64, which carries bytes a JSON string can't.
+const base64Encoding = "base64"
+
+// apiTextRequest is the body of API requests that write a text jot. The
+// options besides Content and ContentEncoding only apply when a jot is
+// created.
+type apiTextRequest struct {
+	Content         string `json:"content"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	ContentType     string `json:"content_type,omitempty"`
+	Filename        string `json:"filename,omitempty"`
+	TTL             string `json:"ttl,omitempty"`
+	Burn            bool   `json:"burn,omitempty"`
+	Encrypted       bool   `json:"encrypted,omitempty"`
+}
+
+// content returns the content of the request, decoded from its
+// content_encoding.
+func (req apiTextRequest) content() ([]byte, error) {
+	switch req.ContentEncoding {
+	case "":
+		return []byte(req.Content), nil
+	case base64Encoding:
+		content, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			return nil, errors.NewBadRequestError("invalid base64 content").WithCause(err)
+		}
+
+		return content, nil
+	default:
+		return nil, errors.NewBadRequestError(fmt.Sprintf("unknown content encoding: %s", req.ContentEncoding))
+	}
+}
+
+// apiHandler serves the versioned API. It has the same operations as the txt
+// and img routes, which it hands requests to, but always answers with JSON
+// and takes the content of text jots as JSON too. Clients can rely on the
+// routes, bodies and error codes of a version not changing.
+type apiHandler struct {
+	cfg    *config.Config
+	routes []apiRoute
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(jsonResponseWriter); !ok {
+		w = jsonResponseWriter{w}
+	}
+
+	// the handlers the API hands requests to answer with JSON when asked.
+	r.Header.Set("accept", JSONContentType)
+
+	version, tail := shiftPath(r.URL.Path)
+	if version != apiVersion {
+		WriteError(errors.NewBadRequestError(fmt.Sprintf("unknown api version: %s", version)), w)
+
+		return
+	}
+
+	var allowed []string
+
+	for _, route := range h.routes {
+		if !route.match(tail) {
+			continue
+		}
+
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+
+			continue
+		}
+
+		r.URL.Path = tail
+		route.handler.ServeHTTP(w, r)
+
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("allow", strings.Join(allowed, ", "))
+		WriteError(errors.NewMethodNotAllowedError(r.Method), w)
+
+		return
+	}
+
+	WriteError(errors.NewNotFoundError(tail), w)
+}
+
+// spec serves the OpenAPI document of the API.
+func (h *apiHandler) spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", JSONContentType)
+
+	if _, err := w.Write(openAPISpec); err != nil {
+		log.Println(fmt.Errorf("error while writing openapi spec: %w", err))
+	}
+}
+
+// routed hands API requests to the handler of a top level route, such as
+// the jot handler for /txt.
+func routed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, r.URL.Path = shiftPath(r.URL.Path)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withTextRequest turns the JSON body of an API request that writes a text
+// jot into the request the jot handler takes: the content as the body and the
+// options as the query parameters and headers it reads them from.
+func withTextRequest(cfg *config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+		if mediaType != JSONContentType {
+			WriteError(errors.NewUnsupportedFormatError(r.Header.Get("content-type")), w)
+
+			return
+		}
+
+		if err := limitBody(w, r, cfg.MaxRequestSize); err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		var req apiTextRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if isLimitError(err) {
+				WriteError(limitError(err), w)
+			} else {
+				WriteError(errors.NewBadRequestError("invalid request body").WithCause(err), w)
+			}
+
+			return
+		}
+
+		content, err := req.content()
+		if err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		query := r.URL.Query()
+		if req.TTL != "" {
+			query.Set("ttl", req.TTL)
+		}
+
+		if req.Burn {
+			query.Set("burn", "1")
+		}
+
+		if req.Encrypted {
+			query.Set("encrypted", "1")
+		}
+
+		if req.Filename != "" {
+			query.Set("filename", req.Filename)
+		}
+
+		r.URL.RawQuery = query.Encode()
+		r.Header.Del("content-type")
+		r.Header.Del("content-disposition")
+		r.Header.Del("expires-in")
+		r.Header.Del("burn-after-reading")
+
+		if req.ContentType != "" {
+			r.Header.Set("content-type", req.ContentType)
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(content))
+		r.ContentLength = int64(len(content))
+		r.Header.Set("content-length", strconv.Itoa(len(content)))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewAPIHandler returns the handler of the versioned API, which hands its
+// requests to the jot and image handlers.
+func NewAPIHandler(cfg *config.Config, jr *jotHandler, ir *imageHandler) *apiHandler {
+	h := &apiHandler{cfg: cfg}
+
+	txt := routed(jr)
+	txtWrite := withTextRequest(cfg, txt)
+	img := routed(ir)
+
+	h.routes = []apiRoute{
+		{http.MethodGet, "/openapi.json", http.HandlerFunc(h.spec)},
+		{http.MethodPost, "/txt", txtWrite},
+		{http.MethodPost, "/txt/{key}", txtWrite},
+		{http.MethodGet, "/txt/{key}", txt},
+		{http.MethodPut, "/txt/{key}", txtWrite},
+		{http.MethodDelete, "/txt/{key}", txt},
+		{http.MethodPost, "/txt/{key}/append", txtWrite},
+		{http.MethodPost, "/txt/{key}/fork", txt},
+		{http.MethodPost, "/img", img},
+		{http.MethodPost, "/img/{key}", img},
+		{http.MethodGet, "/img/{key}", img},
+		{http.MethodDelete, "/img/{key}", img},
+		{http.MethodGet, "/img/{key}/{name}", img},
+	}
+
+	return h
+}
@@ -0,0 +1,553 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kyleterry/jot/pkg/collab"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/kyleterry/jot/pkg/text"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+// editSaveDelay is how long an edit session waits after a change before it
+// writes the jot, so that every keystroke doesn't make a revision.
+const editSaveDelay = time.Second
+
+// editRequest is an operation sent by an editor, made to version of the jot.
+type editRequest struct {
+	Client  string           `json:"client"`
+	Version int              `json:"version"`
+	Op      collab.Operation `json:"op"`
+}
+
+// editSnapshot is the first event of an edit stream.
+type editSnapshot struct {
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+// editSessions holds the jots being edited. A session starts when the first
+// editor joins and ends, after writing the jot, when the last one leaves.
+type editSessions struct {
+	mu       sync.Mutex
+	store    text.StoreService
+	broker   *events.Broker
+	sessions map[string]*editSession
+}
+
+// editSession is one jot being edited. Its goroutine writes changes to the
+// store and merges changes made to the jot outside of the session.
+type editSession struct {
+	key     string
+	doc     *collab.Document
+	editors int
+	changed chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	// saved is the text last written to or read from the store and
+	// savedVersion the version of doc that holds it. Only the goroutine of
+	// the session uses them.
+	saved        string
+	savedVersion int
+	// failing is set while writing the jot fails. Only the goroutine of the
+	// session uses it.
+	failing bool
+
+	mu sync.Mutex
+	// watchers are told the outcome of writes of the jot, see watchSaves.
+	watchers map[chan error]struct{}
+}
+
+// join adds an editor to the session of a jot, starting it if there's none.
+func (s *editSessions) join(ctx context.Context, key string) (*editSession, error) {
+	if session := s.joinRunning(key); session != nil {
+		return session, nil
+	}
+
+	// the jot is read without the lock, so loading it doesn't hold up the
+	// sessions of other jots.
+	jotFile, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer jotFile.Content.Close()
+
+	raw, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &editSession{
+		key:      key,
+		doc:      collab.NewDocument(string(raw)),
+		editors:  1,
+		changed:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		saved:    string(raw),
+		watchers: make(map[chan error]struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// another editor may have started a session while the jot was read.
+	if running, ok := s.sessions[key]; ok {
+		running.editors++
+
+		return running, nil
+	}
+
+	s.sessions[key] = session
+
+	go session.run(s)
+
+	return session, nil
+}
+
+// joinRunning adds an editor to the session of a jot if there is one.
+func (s *editSessions) joinRunning(key string) *editSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if ok {
+		session.editors++
+	}
+
+	return session
+}
+
+// get returns the session of a jot, or nil if nobody is editing it.
+func (s *editSessions) get(key string) *editSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[key]
+}
+
+// leave removes an editor from a session. The last editor to leave ends the
+// session once the jot is written.
+func (s *editSessions) leave(session *editSession) {
+	s.mu.Lock()
+
+	session.editors--
+	if session.editors > 0 {
+		s.mu.Unlock()
+
+		return
+	}
+
+	s.remove(session)
+	s.mu.Unlock()
+
+	// the session removes itself when it ends, so it can't be waited for
+	// with the lock held.
+	close(session.stop)
+	<-session.done
+}
+
+// remove takes a session out of the map, unless a newer session of the jot
+// already replaced it. s.mu must be held.
+func (s *editSessions) remove(session *editSession) {
+	if s.sessions[session.key] == session {
+		delete(s.sessions, session.key)
+	}
+}
+
+func (es *editSession) run(s *editSessions) {
+	defer close(es.done)
+
+	// the session also ends when the jot is deleted, and then nobody can
+	// join it anymore.
+	defer func() {
+		s.mu.Lock()
+		s.remove(es)
+		s.mu.Unlock()
+	}()
+
+	store := s.store
+
+	var sub <-chan events.Event
+
+	if s.broker != nil {
+		ch, unsubscribe := s.broker.Subscribe(es.key)
+		defer unsubscribe()
+
+		sub = ch
+	}
+
+	var save <-chan time.Time
+
+	// write saves the text, and tries again after a while if that fails so
+	// the changes aren't lost.
+	write := func() {
+		err := es.save(store)
+		if err != nil {
+			log.Println(fmt.Errorf("error while saving edit session: %w", err))
+
+			save = time.After(editSaveDelay)
+		}
+
+		if err != nil || es.failing {
+			es.failing = err != nil
+			es.report(err)
+		}
+	}
+
+	for {
+		select {
+		case <-es.stop:
+			write()
+
+			return
+		case <-es.changed:
+			if save == nil {
+				save = time.After(editSaveDelay)
+			}
+		case <-save:
+			save = nil
+			write()
+		case e := <-sub:
+			if e.Type == events.Deleted {
+				es.doc.Close()
+
+				return
+			}
+
+			// the store now holds what was reloaded, and the merged text
+			// is written if it's any different.
+			if es.reload(store) {
+				write()
+			}
+		}
+	}
+}
+
+// save writes the text of the session to the store if it changed.
+func (es *editSession) save(store text.StoreService) error {
+	content, version := es.doc.Text()
+	if content == es.saved {
+		return nil
+	}
+
+	jotFile := &types.TextFile{Key: es.key, Content: io.NopCloser(strings.NewReader(content))}
+	if err := store.Update(context.Background(), jotFile); err != nil {
+		return err
+	}
+
+	// the event the write causes is handled after this, so it's known to be
+	// this session's own.
+	es.saved, es.savedVersion = content, version
+
+	return nil
+}
+
+// watchSaves returns a channel that gets the error of every write of the jot
+// that fails, and nil for the first one that succeeds after, so editors can
+// be told their changes aren't stored.
+func (es *editSession) watchSaves() (<-chan error, func()) {
+	ch := make(chan error, 1)
+
+	es.mu.Lock()
+	es.watchers[ch] = struct{}{}
+	es.mu.Unlock()
+
+	return ch, func() {
+		es.mu.Lock()
+		delete(es.watchers, ch)
+		es.mu.Unlock()
+	}
+}
+
+// report tells the watchers the outcome of a write. Watchers that haven't
+// taken the last outcome yet miss this one.
+func (es *editSession) report(err error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	for ch := range es.watchers {
+		select {
+		case ch <- err:
+		default:
+		}
+	}
+}
+
+// reload merges changes made to the jot outside of the session into it, as
+// an operation made to the version that was last saved. It reports whether
+// there were any.
+func (es *editSession) reload(store text.StoreService) bool {
+	jotFile, err := store.Get(context.Background(), es.key)
+	if err != nil {
+		log.Println(fmt.Errorf("error while reloading edit session: %w", err))
+
+		return false
+	}
+
+	raw, err := io.ReadAll(jotFile.Content)
+	jotFile.Content.Close()
+
+	if err != nil {
+		log.Println(fmt.Errorf("error while reloading edit session: %w", err))
+
+		return false
+	}
+
+	if string(raw) == es.saved {
+		return false
+	}
+
+	op := collab.Diff(utf16.Encode([]rune(es.saved)), utf16.Encode([]rune(string(raw))))
+	if _, err := es.doc.Apply("", es.savedVersion, op); err != nil {
+		// the saved version is too old to merge with, so the change
+		// replaces the text.
+		current, version := es.doc.Text()
+
+		op = collab.Diff(utf16.Encode([]rune(current)), utf16.Encode([]rune(string(raw))))
+		if _, err := es.doc.Apply("", version, op); err != nil {
+			log.Println(fmt.Errorf("error while reloading edit session: %w", err))
+
+			return false
+		}
+	}
+
+	_, es.savedVersion = es.doc.Text()
+	es.saved = string(raw)
+
+	return true
+}
+
+func newEditSessions(store text.StoreService, broker *events.Broker) *editSessions {
+	return &editSessions{
+		store:    store,
+		broker:   broker,
+		sessions: make(map[string]*editSession),
+	}
+}
+
+// edit serves the collaborative editor page of a jot. The page asks for the
+// password before it joins the edit session.
+func (h jotHandler) edit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := editorPage(jotFile.Key).Render(ctx, w); err != nil {
+		log.Println(fmt.Errorf("error while rendering editor page: %w", err))
+	}
+}
+
+// editForm serves the page that edits a jot on its own.
+func (h jotHandler) editForm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	if err := editable(jotFile); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	jotFile, err := h.store.Get(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	defer jotFile.Content.Close()
+
+	raw, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to read jot").WithCause(err), w)
+
+		return
+	}
+
+	w.Header().Set("content-type", HTMLContentType)
+	w.Header().Set("cache-control", "no-store")
+
+	if err := formEditorPage(jotFile.Key, string(raw), jotFile.ETag()).Render(ctx, w); err != nil {
+		log.Println(fmt.Errorf("error while rendering editor page: %w", err))
+	}
+}
+
+// editStream joins the edit session of a jot and streams it as server-sent
+// events: a snapshot event with the text and its version, then an op event
+// for every change made to it. The stream ends when the editor falls too far
+// behind or the jot is deleted, and editors join again to carry on.
+func (h jotHandler) editStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	if err := editable(jotFile); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	session, err := h.sessions.join(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	defer h.sessions.leave(session)
+
+	content, version, changes, cancel := session.doc.Subscribe()
+	defer cancel()
+
+	saves, unwatch := session.watchSaves()
+	defer unwatch()
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	raw, err := json.Marshal(editSnapshot{Version: version, Text: content})
+	if err == nil {
+		err = writeEvent(w, "snapshot", string(raw))
+	}
+
+	if err != nil {
+		log.Println(fmt.Errorf("error while streaming edits: %w", err))
+
+		return
+	}
+
+	rc.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			var raw []byte
+
+			raw, err = json.Marshal(change)
+			if err == nil {
+				err = writeEvent(w, "op", string(raw))
+			}
+		case saveErr := <-saves:
+			// editors are told when their changes can't be stored and
+			// when they are again.
+			if saveErr != nil {
+				err = writeEvent(w, "error", saveErr.Error())
+			} else {
+				err = writeEvent(w, "saved", "")
+			}
+		}
+
+		if err != nil {
+			log.Println(fmt.Errorf("error while streaming edits: %w", err))
+
+			return
+		}
+
+		rc.Flush()
+	}
+}
+
+// editOp applies an operation sent by an editor to the edit session of a jot.
+// The editor learns it was applied from its own op event in the stream.
+func (h jotHandler) editOp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	if err := editable(jotFile); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if err := limitBody(w, r, textLimit(h.cfg)); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	var req editRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isLimitError(err) {
+			WriteError(limitError(err), w)
+		} else {
+			WriteError(errors.NewBadRequestError("invalid operation").WithCause(err), w)
+		}
+
+		return
+	}
+
+	session := h.sessions.get(jotFile.Key)
+	if session == nil {
+		WriteError(errors.NewBadRequestError("nobody is editing this jot"), w)
+
+		return
+	}
+
+	if limit := textLimit(h.cfg); limit > 0 {
+		content, _ := session.doc.Text()
+
+		size := len(content)
+		for _, c := range req.Op {
+			size += len(c.Insert)
+		}
+
+		if int64(size) > limit {
+			WriteError(newBodyTooLargeError(limit), w)
+
+			return
+		}
+	}
+
+	if _, err := session.doc.Apply(req.Client, req.Version, req.Op); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	select {
+	case session.changed <- struct{}{}:
+	default:
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// editable returns an error for jots that can't be edited in the browser.
+func editable(jotFile *types.TextFile) error {
+	switch {
+	case jotFile.Encrypted:
+		return errors.NewBadRequestError("encrypted jots can't be edited here")
+	case jotFile.BurnAfterReading:
+		return errors.NewBadRequestError("jots that burn after reading can't be edited here")
+	}
+
+	return nil
+}
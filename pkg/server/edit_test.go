@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kyleterry/jot/pkg/collab"
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/kyleterry/jot/pkg/text"
+	"github.com/kyleterry/jot/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// editStore holds one jot in memory, and fails to write it while fail is set.
+type editStore struct {
+	text.StoreService
+
+	mu      sync.Mutex
+	content string
+	fail    bool
+}
+
+func (s *editStore) Get(_ context.Context, key string) (*types.TextFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &types.TextFile{Key: key, Content: io.NopCloser(strings.NewReader(s.content))}, nil
+}
+
+func (s *editStore) Update(_ context.Context, jf *types.TextFile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return errors.New("disk full")
+	}
+
+	raw, err := io.ReadAll(jf.Content)
+	if err != nil {
+		return err
+	}
+
+	s.content = string(raw)
+
+	return nil
+}
+
+func (s *editStore) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail = fail
+}
+
+func (s *editStore) text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.content
+}
+
+// edit changes the text of the session to content, like an editor would.
+func edit(t *testing.T, session *editSession, content string) {
+	t.Helper()
+
+	current, version := session.doc.Text()
+	op := collab.Diff(utf16.Encode([]rune(current)), utf16.Encode([]rune(content)))
+
+	_, err := session.doc.Apply("editor", version, op)
+	require.NoError(t, err)
+
+	select {
+	case session.changed <- struct{}{}:
+	default:
+	}
+}
+
+func TestEditSessionSaveFailure(t *testing.T) {
+	store := &editStore{content: "hello\n", fail: true}
+	sessions := newEditSessions(store, nil)
+
+	session, err := sessions.join(context.Background(), "abc")
+	require.NoError(t, err)
+
+	saves, unwatch := session.watchSaves()
+	defer unwatch()
+
+	edit(t, session, "hello world\n")
+
+	select {
+	case err := <-saves:
+		require.ErrorContains(t, err, "disk full")
+	case <-time.After(5 * time.Second):
+		t.Fatal("editors weren't told the save failed")
+	}
+
+	require.Equal(t, "hello\n", store.text())
+
+	// the edits are written once the store works again.
+	store.setFail(false)
+
+	select {
+	case err := <-saves:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the save wasn't retried")
+	}
+
+	require.Equal(t, "hello world\n", store.text())
+
+	sessions.leave(session)
+}
+
+func TestEditSessionDeleted(t *testing.T) {
+	store := &editStore{content: "hello\n"}
+	broker := events.NewBroker()
+	sessions := newEditSessions(store, broker)
+
+	session, err := sessions.join(context.Background(), "abc")
+	require.NoError(t, err)
+
+	// the session subscribes to events once it runs, so the delete is
+	// published until it ends.
+	require.Eventually(t, func() bool {
+		broker.Publish(events.Event{Key: "abc", Type: events.Deleted})
+
+		select {
+		case <-session.done:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond, "the session didn't end when the jot was deleted")
+
+	require.Nil(t, sessions.get("abc"))
+
+	// a jot made under the same key gets a session of its own, which the
+	// editor of the deleted one leaving doesn't end.
+	store.mu.Lock()
+	store.content = "again\n"
+	store.mu.Unlock()
+
+	next, err := sessions.join(context.Background(), "abc")
+	require.NoError(t, err)
+	require.NotSame(t, session, next)
+
+	sessions.leave(session)
+	require.Same(t, next, sessions.get("abc"))
+
+	text, _ := next.doc.Text()
+	require.Equal(t, "again\n", text)
+
+	sessions.leave(next)
+	require.Nil(t, sessions.get("abc"))
+}
+
+// slowStore is an editStore that takes until release is closed to read the
+// jot under key slow, and tells loading when it starts to.
+type slowStore struct {
+	*editStore
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) Get(ctx context.Context, key string) (*types.TextFile, error) {
+	if key == "slow" {
+		s.loading <- struct{}{}
+		<-s.release
+	}
+
+	return s.editStore.Get(ctx, key)
+}
+
+func TestEditSessionJoinWhileLoading(t *testing.T) {
+	store := &slowStore{
+		editStore: &editStore{content: "hello\n"},
+		loading:   make(chan struct{}, 2),
+		release:   make(chan struct{}),
+	}
+	sessions := newEditSessions(store, nil)
+
+	joined := make(chan *editSession, 2)
+
+	for range 2 {
+		go func() {
+			session, err := sessions.join(context.Background(), "slow")
+			require.NoError(t, err)
+
+			joined <- session
+		}()
+	}
+
+	<-store.loading
+
+	// a jot that takes a while to load doesn't keep editors from joining
+	// other jots.
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		session, err := sessions.join(context.Background(), "fast")
+		require.NoError(t, err)
+
+		sessions.leave(session)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("joining a session waited for another jot to load")
+	}
+
+	// both editors of the slow jot end up in the same session.
+	close(store.release)
+
+	first, second := <-joined, <-joined
+	require.Same(t, first, second)
+	require.Equal(t, 2, first.editors)
+
+	sessions.leave(first)
+	sessions.leave(second)
+	require.Nil(t, sessions.get("slow"))
+}
@@ -0,0 +1,228 @@
+// Code generated by templ - DO NOT EDIT.
+
+// templ: version: v0.3.1001
+package server
+
+//lint:file-ignore SA4006 This context is only used if a nested component is present.
+
+import "github.com/a-h/templ"
+import templruntime "github.com/a-h/templ/runtime"
+
+import "fmt"
+
+func editorStyle() templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var1 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var1 == nil {
+			templ_7745c5c3_Var1 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<style>\n    textarea {\n      background-color: #1d2021;\n      border: 1px solid #3c3836;\n      box-sizing: border-box;\n      color: #d4be98;\n      font-family: monospace;\n      height: 80vh;\n      padding: 5px;\n      width: 100%;\n    }\n\n    .status {\n      color: #928374;\n      float: right;\n    }\n  </style>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// editorPage lets several people edit a jot at once. Changes are sent as
+// operations to the edit session of the jot and changes made by others
+// arrive on its event stream; see edit.go for the protocol.
+func editorPage(key string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var2 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var2 == nil {
+			templ_7745c5c3_Var2 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var3 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = editorStyle().Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, " <div class=\"nav\"><a href=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 templ.SafeURL
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinURLErrs(templ.URL("/txt/" + key))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 32, Col: 37}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(key)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 32, Col: 45}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</a> · <a href=\"?form=1\">edit on your own</a> <span class=\"status\" id=\"status\"></span></div><form id=\"join\"><label>Password <input type=\"password\" name=\"password\" autofocus></label> <button type=\"submit\">Edit</button></form><textarea id=\"editor\" spellcheck=\"false\" hidden></textarea><script>\n    (function() {\n      var url = location.pathname;\n      var form = document.getElementById(\"join\");\n      var editor = document.getElementById(\"editor\");\n      var status = document.getElementById(\"status\");\n\n      var client = Math.random().toString(36).slice(2);\n      var auth = \"\";\n      var version = 0;\n      // pending is the text the server will have once the outstanding\n      // operation is applied. What's typed while an operation is\n      // outstanding is sent as the next one.\n      var pending = \"\";\n      var outstanding = null;\n      var stream = null;\n\n      // operations are lists of positive retains, negative deletes and\n      // string inserts, counted in UTF-16 code units like pkg/collab.\n      function isInsert(c) { return typeof c === \"string\"; }\n      function isRetain(c) { return typeof c === \"number\" && c > 0; }\n\n      function push(op, c) {\n        if (c === 0 || c === \"\") {\n          return;\n        }\n\n        var last = op[op.length - 1];\n        if (op.length && (isInsert(last) ? isInsert(c) : (isRetain(last) === isRetain(c) && !isInsert(c)))) {\n          op[op.length - 1] = last + c;\n        } else {\n          op.push(c);\n        }\n      }\n\n      function apply(op, text) {\n        var out = \"\";\n        var pos = 0;\n\n        op.forEach(function(c) {\n          if (isInsert(c)) {\n            out += c;\n          } else if (c > 0) {\n            out += text.slice(pos, pos + c);\n            pos += c;\n          } else {\n            pos -= c;\n          }\n        });\n\n        return out;\n      }\n\n      function transform(a, b) {\n        var aPrime = [], bPrime = [];\n        var i = 0, j = 0;\n        var ca = a[i++], cb = b[j++];\n\n        while (ca !== undefined || cb !== undefined) {\n          if (isInsert(ca)) {\n            push(aPrime, ca);\n            push(bPrime, ca.length);\n            ca = a[i++];\n            continue;\n          }\n\n          if (isInsert(cb)) {\n            push(aPrime, cb.length);\n            push(bPrime, cb);\n            cb = b[j++];\n            continue;\n          }\n\n          var n = Math.min(Math.abs(ca), Math.abs(cb));\n\n          if (ca > 0 && cb > 0) {\n            push(aPrime, n);\n            push(bPrime, n);\n          } else if (ca < 0 && cb > 0) {\n            push(aPrime, -n);\n          } else if (ca > 0 && cb < 0) {\n            push(bPrime, -n);\n          }\n\n          ca = ca > 0 ? ca - n : ca + n;\n          cb = cb > 0 ? cb - n : cb + n;\n\n          if (ca === 0) {\n            ca = a[i++];\n          }\n\n          if (cb === 0) {\n            cb = b[j++];\n          }\n        }\n\n        return [aPrime, bPrime];\n      }\n\n      function diff(a, b) {\n        var prefix = 0;\n        while (prefix < a.length && prefix < b.length && a[prefix] === b[prefix]) {\n          prefix++;\n        }\n\n        var suffix = 0;\n        while (suffix < a.length - prefix && suffix < b.length - prefix && a[a.length - 1 - suffix] === b[b.length - 1 - suffix]) {\n          suffix++;\n        }\n\n        // never split a surrogate pair\n        if (prefix > 0 && /[\\ud800-\\udbff]/.test(a[prefix - 1])) {\n          prefix--;\n        }\n\n        if (suffix > 0 && /[\\udc00-\\udfff]/.test(a[a.length - suffix])) {\n          suffix--;\n        }\n\n        var op = [];\n        push(op, prefix);\n        push(op, -(a.length - prefix - suffix));\n        push(op, b.slice(prefix, b.length - suffix));\n        push(op, suffix);\n\n        return op;\n      }\n\n      // moves a cursor position over an operation made by someone else\n      function shift(op, cursor) {\n        var pos = 0;\n        var out = cursor;\n\n        for (var k = 0; k < op.length && pos < cursor; k++) {\n          var c = op[k];\n\n          if (isInsert(c)) {\n            out += c.length;\n          } else if (c > 0) {\n            pos += c;\n          } else {\n            out -= Math.min(-c, cursor - pos);\n            pos -= c;\n          }\n        }\n\n        return out;\n      }\n\n      function setStatus(text) {\n        status.textContent = text;\n      }\n\n      async function send() {\n        if (outstanding || editor.value === pending) {\n          return;\n        }\n\n        outstanding = diff(pending, editor.value);\n        pending = editor.value;\n        setStatus(\"saving…\");\n\n        var resp = await fetch(url, {\n          method: \"POST\",\n          headers: {\"Authorization\": auth, \"Content-Type\": \"application/json\"},\n          body: JSON.stringify({client: client, version: version, op: outstanding})\n        });\n        if (!resp.ok) {\n          setStatus(\"could not save: \" + (await resp.text()).trim());\n          restart();\n        }\n      }\n\n      function onSnapshot(data) {\n        version = data.version;\n        pending = data.text;\n        outstanding = null;\n        editor.value = data.text;\n        editor.hidden = false;\n        setStatus(\"editing\");\n      }\n\n      function onOp(change) {\n        version = change.version;\n\n        if (change.client === client) {\n          outstanding = null;\n          setStatus(\"saved\");\n          send();\n          return;\n        }\n\n        // the change is transformed against the outstanding operation,\n        // then against what was typed since it was sent.\n        var op = change.op;\n        if (outstanding) {\n          var pair = transform(outstanding, op);\n          outstanding = pair[0];\n          op = pair[1];\n        }\n\n        var remote = transform(diff(pending, editor.value), op)[1];\n        pending = apply(op, pending);\n\n        var start = shift(remote, editor.selectionStart);\n        var end = shift(remote, editor.selectionEnd);\n\n        editor.value = apply(remote, editor.value);\n        editor.setSelectionRange(start, end);\n      }\n\n      function connect() {\n        stream = new AbortController();\n\n        fetch(url, {\n          headers: {\"Accept\": \"text/event-stream\", \"Authorization\": auth},\n          signal: stream.signal\n        }).then(async function(resp) {\n          if (resp.status === 401) {\n            form.hidden = false;\n            editor.hidden = true;\n            setStatus(\"wrong password\");\n            return;\n          }\n\n          if (!resp.ok) {\n            setStatus((await resp.text()).trim());\n            return;\n          }\n\n          var reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();\n          var buf = \"\";\n\n          for (;;) {\n            var chunk = await reader.read();\n            if (chunk.done) {\n              break;\n            }\n\n            buf += chunk.value;\n\n            var end;\n            while ((end = buf.indexOf(\"\\n\\n\")) >= 0) {\n              var event = \"\", data = [];\n\n              buf.slice(0, end).split(\"\\n\").forEach(function(line) {\n                if (line.startsWith(\"event: \")) {\n                  event = line.slice(7);\n                } else if (line.startsWith(\"data: \")) {\n                  data.push(line.slice(6));\n                }\n              });\n\n              buf = buf.slice(end + 2);\n\n              if (event === \"snapshot\") {\n                onSnapshot(JSON.parse(data.join(\"\\n\")));\n              } else if (event === \"op\") {\n                onOp(JSON.parse(data.join(\"\\n\")));\n              } else if (event === \"error\") {\n                setStatus(\"changes not stored, retrying: \" + data.join(\"\\n\"));\n              } else if (event === \"saved\") {\n                setStatus(\"saved\");\n              }\n            }\n          }\n\n          setStatus(\"reconnecting…\");\n          setTimeout(connect, 1000);\n        }).catch(function(err) {\n          if (err.name !== \"AbortError\") {\n            setStatus(\"reconnecting…\");\n            setTimeout(connect, 1000);\n          }\n        });\n      }\n\n      function restart() {\n        if (stream) {\n          stream.abort();\n        }\n\n        setTimeout(connect, 1000);\n      }\n\n      editor.addEventListener(\"input\", send);\n\n      form.addEventListener(\"submit\", function(e) {\n        e.preventDefault();\n        auth = \"Basic \" + btoa(\":\" + form.password.value);\n        form.hidden = true;\n        connect();\n      });\n    })();\n  </script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = layout(fmt.Sprintf("jot edit: %s", key)).Render(templ.WithChildren(ctx, templ_7745c5c3_Var3), templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// formEditorPage edits a jot on its own, for when the collaborative editor
+// isn't wanted. It saves with the password and the ETag of the content it
+// was opened with, so edits made in the meantime aren't overwritten.
+func formEditorPage(key, content, etag string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var6 == nil {
+			templ_7745c5c3_Var6 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var7 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = editorStyle().Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, " <div class=\"nav\"><a href=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var8 templ.SafeURL
+			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinURLErrs(templ.URL("/txt/" + key))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 346, Col: 37}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var9 string
+			templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(key)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 346, Col: 45}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</a> <span class=\"status\" id=\"status\"></span></div><form id=\"save\" data-etag=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var10 string
+			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(etag)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 349, Col: 34}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "\"><textarea name=\"content\" spellcheck=\"false\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var11 string
+			templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(content)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/editor.templ`, Line: 350, Col: 56}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</textarea><div class=\"nav\"><label>Password <input type=\"password\" name=\"password\"></label> <button type=\"submit\">Save</button></div></form><script>\n    (function() {\n      var url = location.pathname.replace(/\\/edit$/, \"\");\n      var form = document.getElementById(\"save\");\n      var status = document.getElementById(\"status\");\n\n      form.addEventListener(\"submit\", async function(e) {\n        e.preventDefault();\n        status.textContent = \"saving…\";\n\n        try {\n          var resp = await fetch(url, {\n            method: \"PUT\",\n            headers: {\n              \"Authorization\": \"Basic \" + btoa(\":\" + form.password.value),\n              \"Content-Type\": \"text/plain; charset=utf-8\",\n              \"If-Match\": form.dataset.etag\n            },\n            body: form.content.value,\n            redirect: \"manual\"\n          });\n\n          // a saved jot redirects to itself\n          if (resp.type !== \"opaqueredirect\" && !resp.ok) {\n            if (resp.status === 401) {\n              throw new Error(\"wrong password\");\n            }\n            if (resp.status === 412) {\n              throw new Error(\"the jot was changed since you opened it, reload to get the changes\");\n            }\n            throw new Error((await resp.text()).trim());\n          }\n\n          var saved = await fetch(url, {cache: \"no-store\"});\n          form.dataset.etag = saved.headers.get(\"ETag\");\n          status.textContent = \"saved\";\n        } catch (err) {\n          status.textContent = \"could not save: \" + err.message;\n        }\n      });\n    })();\n  </script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = layout(fmt.Sprintf("jot edit: %s", key)).Render(templ.WithChildren(ctx, templ_7745c5c3_Var7), templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+var _ = templruntime.GeneratedTemplate
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+// keepAliveInterval is how often idle streams get a comment so proxies don't
+// close them.
+const keepAliveInterval = 30 * time.Second
+
+// events streams changes to a jot as server-sent events. Content added to the
+// end of the jot is sent in append events, edits and restores are sent as
+// revision events with the revision number, ETag and size, and the stream ends
+// with a delete event when the jot is deleted.
+func (h jotHandler) events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	if jotFile.BurnAfterReading {
+		WriteError(errors.NewNotFoundError(jotFile.Key), w)
+
+		return
+	}
+
+	sub, unsubscribe := h.broker.Subscribe(jotFile.Key)
+	defer unsubscribe()
+
+	revisions, err := h.store.Revisions(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	offset := revisions[len(revisions)-1].Size
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case e := <-sub:
+			switch e.Type {
+			case events.Appended:
+				var content []byte
+
+				content, err = h.readFrom(ctx, jotFile.Key, offset)
+				if err == nil && len(content) > 0 {
+					offset += int64(len(content))
+					err = writeEvent(w, "append", string(content))
+				}
+			case events.Updated:
+				revisions, err = h.store.Revisions(ctx, jotFile.Key)
+				if err == nil {
+					rev := revisions[len(revisions)-1]
+					offset = rev.Size
+					err = writeEvent(w, "revision", fmt.Sprintf("%d %s %d", rev.Number, rev.ETag(), rev.Size))
+				}
+			case events.Deleted:
+				if err := writeEvent(w, "delete", jotFile.Key); err == nil {
+					rc.Flush()
+				}
+
+				return
+			}
+		}
+
+		if err != nil {
+			log.Println(fmt.Errorf("error while streaming events: %w", err))
+
+			return
+		}
+
+		rc.Flush()
+	}
+}
+
+// follow writes the content of a jot and then keeps the response open, writing
+// content as it's added, until the jot is deleted or replaced with content that
+// doesn't start with what was already sent. If done is not nil the response
+// also ends, after writing the rest of the content, once done is closed.
+func (h jotHandler) follow(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile, done <-chan struct{}) {
+	ctx := r.Context()
+
+	sub, unsubscribe := h.broker.Subscribe(jotFile.Key)
+	defer unsubscribe()
+
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	sent := sha256.New()
+
+	offset, err := io.Copy(io.MultiWriter(w, sent), jotFile.Content)
+	if err != nil {
+		log.Println(fmt.Errorf("error while writing jot: %w", err))
+
+		return
+	}
+
+	rc.Flush()
+
+	// anything written between loading the jot and subscribing would
+	// otherwise be missed.
+	next := make(chan events.Event, 1)
+	next <- events.Event{Key: jotFile.Key, Type: events.Appended}
+
+	var finished bool
+
+	for !finished {
+		var e events.Event
+
+		select {
+		case <-ctx.Done():
+			return
+		case e = <-next:
+		case e = <-sub:
+		case <-done:
+			// the stream has ended, so whatever is stored now is the
+			// rest of the jot.
+			finished = true
+			e = events.Event{Key: jotFile.Key, Type: events.Appended}
+		}
+
+		var content []byte
+
+		switch e.Type {
+		case events.Appended:
+			content, err = h.readFrom(ctx, jotFile.Key, offset)
+		case events.Updated:
+			content, err = h.readContinuation(ctx, jotFile.Key, offset, sent)
+		case events.Deleted:
+			return
+		}
+
+		if err != nil {
+			if !errors.IsStoreError(err) {
+				log.Println(fmt.Errorf("error while following jot: %w", err))
+			}
+
+			return
+		}
+
+		if len(content) == 0 {
+			continue
+		}
+
+		if _, err := io.MultiWriter(w, sent).Write(content); err != nil {
+			return
+		}
+
+		offset += int64(len(content))
+
+		rc.Flush()
+	}
+}
+
+// readFrom returns the content of a jot after offset. Content that can seek,
+// such as jots stored in blobs, isn't read before offset.
+func (h jotHandler) readFrom(ctx context.Context, key string, offset int64) ([]byte, error) {
+	jotFile, err := h.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer jotFile.Content.Close()
+
+	if seeker, ok := jotFile.Content.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if _, err := io.CopyN(io.Discard, jotFile.Content, offset); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(jotFile.Content)
+}
+
+// readContinuation returns the content of a jot after offset if the content
+// before offset is still what was sent. Otherwise the jot was replaced and a
+// not found error is returned.
+func (h jotHandler) readContinuation(ctx context.Context, key string, offset int64, sent hash.Hash) ([]byte, error) {
+	jotFile, err := h.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer jotFile.Content.Close()
+
+	content, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) < offset {
+		return nil, errors.NewNotFoundError(key)
+	}
+
+	prefix := sha256.Sum256(content[:offset])
+	if !bytes.Equal(prefix[:], sent.Sum(nil)) {
+		return nil, errors.NewNotFoundError(key)
+	}
+
+	return content[offset:], nil
+}
+
+// writeEvent writes a server-sent event. Every line of data is sent as its own
+// data field so clients get the data back with the newlines in place. Clients
+// end lines at \r and \r\n too, so those come back as \n.
+func writeEvent(w io.Writer, event, data string) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "event: %s\n", event)
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
@@ -0,0 +1,15 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEvent(t *testing.T) {
+	var sb strings.Builder
+
+	require.NoError(t, writeEvent(&sb, "append", "one\r\ntwo\rthree\nfour"))
+	require.Equal(t, "event: append\ndata: one\ndata: two\ndata: three\ndata: four\n\n", sb.String())
+}
@@ -0,0 +1,120 @@
+// Code generated by templ - DO NOT EDIT.
+
+// templ: version: v0.3.1001
+package server
+
+//lint:file-ignore SA4006 This context is only used if a nested component is present.
+
+import "github.com/a-h/templ"
+import templruntime "github.com/a-h/templ/runtime"
+
+func homeStyle() templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var1 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var1 == nil {
+			templ_7745c5c3_Var1 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<style>\n    textarea {\n      background-color: #1d2021;\n      border: 1px solid #3c3836;\n      box-sizing: border-box;\n      color: #d4be98;\n      font-family: monospace;\n      padding: 5px;\n      width: 100%;\n    }\n\n    .options {\n      padding: 10px 0;\n    }\n\n    .drop {\n      border: 2px dashed #3c3836;\n      color: #928374;\n      cursor: pointer;\n      padding: 30px;\n      text-align: center;\n    }\n\n    .drop.over {\n      border-color: #d4be98;\n      color: #d4be98;\n    }\n\n    .result {\n      padding: 10px 0;\n    }\n  </style>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// homePage is what browsers get for /. It creates text jots from the paste
+// form and image galleries from files dropped, picked or pasted onto the
+// page. help is the plain text help curl gets, shown below them.
+func homePage(index IndexTemplateContext, help string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var2 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var2 == nil {
+			templ_7745c5c3_Var2 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var3 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = homeStyle().Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, " <div class=\"nav\">jot ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 string
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(index.Version)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/home.templ`, Line: 45, Col: 22}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, " · <a href=\"/private\">private jot</a></div><form id=\"create\"><textarea name=\"content\" rows=\"20\" placeholder=\"Paste or write something\" autofocus></textarea><div class=\"options\"><label>Expires <select name=\"ttl\"><option value=\"\">never</option> <option value=\"10m\">after 10 minutes</option> <option value=\"1h\">after an hour</option> <option value=\"24h\">after a day</option> <option value=\"168h\">after a week</option></select></label> <label><input type=\"checkbox\" name=\"burn\"> burn after reading</label> <button type=\"submit\">Create</button></div></form><div class=\"drop\" id=\"drop\">Drop images here, paste them or click to pick them <input type=\"file\" id=\"files\" accept=\"image/*\" multiple hidden></div><div class=\"result\" id=\"result\"></div><pre>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(help)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/server/home.templ`, Line: 69, Col: 13}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</pre><script>\n    (function() {\n      var form = document.getElementById(\"create\");\n      var drop = document.getElementById(\"drop\");\n      var files = document.getElementById(\"files\");\n      var result = document.getElementById(\"result\");\n\n      function created(resp, what) {\n        return resp.text().then(function(body) {\n          if (!resp.ok) {\n            throw new Error(body.trim());\n          }\n\n          var url = body.trim();\n\n          var link = document.createElement(\"a\");\n          link.href = url;\n          link.textContent = url;\n\n          var password = document.createElement(\"p\");\n          password.textContent = \"Password to edit or delete the \" + what + \": \" + resp.headers.get(\"Jot-Password\");\n\n          result.replaceChildren(link, password);\n        });\n      }\n\n      function failed(err) {\n        result.textContent = \"Could not upload: \" + err.message;\n      }\n\n      form.addEventListener(\"submit\", function(e) {\n        e.preventDefault();\n\n        var params = new URLSearchParams();\n        if (form.ttl.value) {\n          params.set(\"ttl\", form.ttl.value);\n        }\n        if (form.burn.checked) {\n          params.set(\"burn\", \"1\");\n        }\n\n        fetch(\"/txt?\" + params, {\n          method: \"POST\",\n          headers: {\"Content-Type\": \"text/plain; charset=utf-8\"},\n          body: form.content.value\n        }).then(function(resp) {\n          return created(resp, \"jot\");\n        }).then(function() {\n          form.reset();\n        }).catch(failed);\n      });\n\n      function upload(list) {\n        var images = Array.from(list).filter(function(file) {\n          return file.type.startsWith(\"image/\");\n        });\n        if (!images.length) {\n          return;\n        }\n\n        var body = new FormData();\n        images.forEach(function(file, i) {\n          body.append(\"images\", file, file.name || \"pasted-\" + (i + 1) + \".\" + file.type.split(\"/\")[1]);\n        });\n\n        result.textContent = \"Uploading…\";\n\n        fetch(\"/img\", {method: \"POST\", body: body}).then(function(resp) {\n          return created(resp, \"gallery\");\n        }).catch(failed);\n      }\n\n      drop.addEventListener(\"click\", function() {\n        files.click();\n      });\n\n      files.addEventListener(\"change\", function() {\n        upload(files.files);\n        files.value = \"\";\n      });\n\n      drop.addEventListener(\"dragover\", function(e) {\n        e.preventDefault();\n        drop.classList.add(\"over\");\n      });\n\n      drop.addEventListener(\"dragleave\", function() {\n        drop.classList.remove(\"over\");\n      });\n\n      drop.addEventListener(\"drop\", function(e) {\n        e.preventDefault();\n        drop.classList.remove(\"over\");\n        upload(e.dataTransfer.files);\n      });\n\n      // pasted text goes into the form as usual, pasted images are uploaded\n      document.addEventListener(\"paste\", function(e) {\n        if (e.clipboardData.files.length) {\n          e.preventDefault();\n          upload(e.clipboardData.files);\n        }\n      });\n    })();\n  </script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = layout("jot").Render(templ.WithChildren(ctx, templ_7745c5c3_Var3), templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+var _ = templruntime.GeneratedTemplate
@@ -11,6 +11,7 @@ import (
 
 	"github.com/kyleterry/jot/pkg/auth"
 	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
 	"github.com/kyleterry/jot/pkg/image"
 	"github.com/kyleterry/jot/pkg/types"
 )
@@ -38,8 +39,25 @@ func (h *imageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *imageHandler) post(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, err.Error(), http.StatusExpectationFailed)
+	if err := limitBody(w, r, h.cfg.MaxRequestSize); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		if isLimitError(err) {
+			WriteError(limitError(err), w)
+		} else {
+			WriteError(errors.NewBadRequestError("invalid multipart form").WithCause(err), w)
+		}
+
+		return
+	}
+
+	opts, err := createOptionsFromRequest(r)
+	if err != nil {
+		WriteError(err, w)
 
 		return
 	}
@@ -49,11 +67,27 @@ func (h *imageHandler) post(w http.ResponseWriter, r *http.Request) {
 	imageFileHeaders := r.MultipartForm.File["images"]
 
 	if len(imageFileHeaders) == 0 {
-		http.Error(w, "no images found in request", http.StatusBadRequest)
+		WriteError(errors.NewBadRequestError("no images found in request"), w)
+
+		return
+	}
+
+	if h.cfg.MaxImages > 0 && len(imageFileHeaders) > h.cfg.MaxImages {
+		msg := fmt.Sprintf("too many images: a gallery can have at most %d", h.cfg.MaxImages)
+		WriteError(errors.NewTooLargeError(msg), w)
 
 		return
 	}
 
+	for _, header := range imageFileHeaders {
+		if h.cfg.MaxImageSize > 0 && header.Size > h.cfg.MaxImageSize {
+			msg := fmt.Sprintf("image %s is larger than the limit of %d bytes", header.Filename, h.cfg.MaxImageSize)
+			WriteError(errors.NewTooLargeError(msg), w)
+
+			return
+		}
+	}
+
 	for _, header := range imageFileHeaders {
 		file, err := header.Open()
 		if err != nil {
@@ -68,7 +102,7 @@ func (h *imageHandler) post(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	g, err := h.store.Create(r.Context(), images)
+	g, err := h.store.Create(r.Context(), images, opts)
 	if err != nil {
 		WriteError(err, w)
 
@@ -84,11 +118,18 @@ func (h *imageHandler) get(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("etag", gallery.ModifiedDate.Format(time.RFC3339Nano))
 	w.Header().Set("last-modified", gallery.ModifiedDate.Format(http.TimeFormat))
+	setExpiresHeader(w, gallery.ObjectMeta)
 
 	defer gallery.Close()
 
 	_, tail := shiftPath(r.URL.Path)
 	if tail == "" || tail == "/" {
+		if wantsJSON(r) {
+			writeJSON(w, http.StatusOK, newGalleryResponse(h.cfg, r, gallery))
+
+			return
+		}
+
 		if err := galleryPage(gallery).Render(ctx, w); err != nil {
 			log.Println(fmt.Errorf("error while rendering gallery page: %w", err))
 		}
@@ -130,6 +171,12 @@ func (h *imageHandler) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, galleryResponse{Key: gallery.ID, URL: objectURL(h.cfg, r, "img", gallery.ID)})
+
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/kyleterry/jot/pkg/config"
 	"github.com/kyleterry/jot/pkg/version"
@@ -27,7 +29,25 @@ func (h indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Commit:  version.Commit,
 		Host:    host,
 	}
-	if err := render(w, indexTemplate, ctx); err != nil {
+	w.Header().Set("vary", "accept")
+
+	if !acceptsHTML(r) {
+		if err := render(w, indexTemplate, ctx); err != nil {
+			log.Println("err while rendering remplate: ", err)
+		}
+
+		return
+	}
+
+	var help strings.Builder
+
+	if err := render(&help, indexTemplate, ctx); err != nil {
 		log.Println("err while rendering remplate: ", err)
 	}
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := homePage(ctx, help.String()).Render(r.Context(), w); err != nil {
+		log.Println(fmt.Errorf("error while rendering home page: %w", err))
+	}
 }
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+const (
+	// JSONContentType is the content type of JSON responses.
+	JSONContentType = "application/json"
+	// ProblemContentType is the content type of errors sent to clients that
+	// asked for JSON. See RFC 9457.
+	ProblemContentType = "application/problem+json"
+)
+
+// createdResponse is sent for new jots and galleries to clients that asked
+// for JSON.
+type createdResponse struct {
+	Key      string `json:"key"`
+	URL      string `json:"url"`
+	Password string `json:"password"`
+}
+
+// jotResponse describes a text jot to clients that asked for JSON. Content is
+// only set for GET, encoded as ContentEncoding says.
+type jotResponse struct {
+	Key             string     `json:"key"`
+	URL             string     `json:"url"`
+	ETag            string     `json:"etag,omitempty"`
+	Modified        *time.Time `json:"modified,omitempty"`
+	Expires         *time.Time `json:"expires,omitempty"`
+	Size            *int64     `json:"size,omitempty"`
+	ContentType     string     `json:"content_type,omitempty"`
+	Filename        string     `json:"filename,omitempty"`
+	Encrypted       bool       `json:"encrypted,omitempty"`
+	ForkOf          string     `json:"fork_of,omitempty"`
+	Content         *string    `json:"content,omitempty"`
+	ContentEncoding string     `json:"content_encoding,omitempty"`
+}
+
+// galleryResponse describes an image gallery to clients that asked for JSON.
+type galleryResponse struct {
+	Key      string          `json:"key"`
+	URL      string          `json:"url"`
+	ETag     string          `json:"etag,omitempty"`
+	Modified *time.Time      `json:"modified,omitempty"`
+	Expires  *time.Time      `json:"expires,omitempty"`
+	Images   []imageResponse `json:"images,omitempty"`
+}
+
+type imageResponse struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// problem is an error sent to clients that asked for JSON. Code is the
+// errors.ErrorType of the error, which stays the same when messages change.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// jsonResponseWriter marks the response to a request that asked for JSON, so
+// that WriteError, which doesn't get the request, knows to send a problem.
+type jsonResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the wrapped writer.
+func (w jsonResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wantsJSON reports whether the client asked for JSON responses.
+func wantsJSON(r *http.Request) bool {
+	return accepts(r, JSONContentType)
+}
+
+// writeJSON writes v as the JSON response with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("content-type", JSONContentType)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(fmt.Errorf("error while writing json response: %w", err))
+	}
+}
+
+// writeProblem writes a StoreError as a problem.
+func writeProblem(w http.ResponseWriter, err *errors.StoreError) {
+	w.Header().Set("content-type", ProblemContentType)
+	w.Header().Set("x-content-type-options", "nosniff")
+	w.WriteHeader(err.StatusCode)
+
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(err.StatusCode),
+		Status: err.StatusCode,
+		Detail: err.Message,
+		Code:   err.Type.String(),
+	}
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println(fmt.Errorf("error while writing problem: %w", err))
+	}
+}
+
+// objectURL returns the URL of the object under key at routePath.
+func objectURL(cfg *config.Config, r *http.Request, routePath string, key ...string) string {
+	u, err := url.Parse(extractHost(cfg, r))
+	if err != nil {
+		return ""
+	}
+
+	return u.JoinPath(append([]string{routePath}, key...)...).String()
+}
+
+// newJotResponse describes jotFile without its content.
+func newJotResponse(cfg *config.Config, r *http.Request, jotFile *types.TextFile) jotResponse {
+	return jotResponse{
+		Key:         jotFile.Key,
+		URL:         objectURL(cfg, r, "txt", jotFile.Key),
+		ETag:        jotFile.ETag(),
+		Modified:    timeOrNil(jotFile.ModifiedDate),
+		Expires:     timeOrNil(jotFile.ExpiresAt),
+		ContentType: jotFile.ContentType,
+		Filename:    jotFile.Filename,
+		Encrypted:   jotFile.Encrypted,
+		ForkOf:      jotFile.ForkOf,
+	}
+}
+
+// newGalleryResponse describes gallery and lists its images.
+func newGalleryResponse(cfg *config.Config, r *http.Request, gallery *types.GalleryFile) galleryResponse {
+	resp := galleryResponse{
+		Key:      gallery.ID,
+		URL:      objectURL(cfg, r, "img", gallery.ID),
+		ETag:     gallery.ETag(),
+		Modified: timeOrNil(gallery.ModifiedDate),
+		Expires:  timeOrNil(gallery.ExpiresAt),
+	}
+
+	for _, name := range gallery.Images.Keys {
+		img := gallery.Images.Values[name]
+
+		resp.Images = append(resp.Images, imageResponse{
+			Name:        img.Name,
+			URL:         objectURL(cfg, r, "img", gallery.ID, img.Name),
+			ContentType: img.ContentType,
+		})
+	}
+
+	return resp
+}
+
+// timeOrNil returns nil for the zero time, so it's left out of responses.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
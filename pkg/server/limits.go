@@ -0,0 +1,90 @@
+package server
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/errors"
+)
+
+// multipartMemory is how much of a multipart upload is kept in memory. The
+// rest is spooled to temporary files.
+const multipartMemory = 10 << 20
+
+// textLimit returns the most bytes a request can send as the content of a jot,
+// or zero if there's no limit.
+func textLimit(cfg *config.Config) int64 {
+	switch {
+	case cfg.MaxTextSize <= 0:
+		return cfg.MaxRequestSize
+	case cfg.MaxRequestSize <= 0:
+		return cfg.MaxTextSize
+	default:
+		return min(cfg.MaxTextSize, cfg.MaxRequestSize)
+	}
+}
+
+// limitBody makes reading more than limit bytes from the request body fail. A
+// limit of zero or less means no limit. Requests that say up front that their
+// body is too large are refused straight away.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	if r.ContentLength > limit {
+		return newBodyTooLargeError(limit)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	return nil
+}
+
+// limitError turns err into a too large error if it was caused by reading
+// past the limit set with limitBody. Other errors are returned as is.
+func limitError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(err, &maxBytesErr) {
+		return newBodyTooLargeError(maxBytesErr.Limit).WithCause(err)
+	}
+
+	return err
+}
+
+func isLimitError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+
+	return stderrors.As(err, &maxBytesErr)
+}
+
+func newBodyTooLargeError(limit int64) *errors.StoreError {
+	return errors.NewTooLargeError(fmt.Sprintf("request body is larger than the limit of %d bytes", limit))
+}
+
+// appendLimit returns the most bytes a request can append to a jot that holds
+// size bytes, so the jot never grows past MaxTextSize, or zero if there's no
+// limit. A jot that's already full fails with a too large error.
+func appendLimit(cfg *config.Config, size int64) (int64, error) {
+	limit := textLimit(cfg)
+	if cfg.MaxTextSize <= 0 {
+		return limit, nil
+	}
+
+	room := cfg.MaxTextSize - size
+	if room <= 0 {
+		return 0, newJotTooLargeError(cfg.MaxTextSize)
+	}
+
+	if limit <= 0 {
+		return room, nil
+	}
+
+	return min(limit, room), nil
+}
+
+func newJotTooLargeError(limit int64) *errors.StoreError {
+	return errors.NewTooLargeError(fmt.Sprintf("jot would be larger than the limit of %d bytes", limit))
+}
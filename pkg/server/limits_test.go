@@ -0,0 +1,52 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/testutil/testserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejectedJotsLeaveNothing(t *testing.T) {
+	ts := testserver.New(t, func(cfg *config.Config) {
+		cfg.MaxTextSize = 8
+	})
+	client := ts.Client()
+
+	resp, err := client.Post(ts.URL+"/txt", "text/plain", strings.NewReader("too large"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// bodies without a length are cut off while they're read
+	resp, err = client.Post(ts.URL+"/txt", "text/plain", io.MultiReader(strings.NewReader("too large")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	require.Empty(t, dataFiles(t, ts.Config.DataDir))
+}
+
+// dataFiles lists the files in a data dir.
+func dataFiles(t *testing.T, dataDir config.DataDir) []string {
+	t.Helper()
+
+	var files []string
+
+	err := filepath.WalkDir(string(dataDir), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	})
+	require.NoError(t, err)
+
+	return files
+}
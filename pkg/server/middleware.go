@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/kyleterry/jot/pkg/auth"
 	"github.com/kyleterry/jot/pkg/errors"
@@ -166,6 +167,39 @@ func WithKeyRequiredMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// WithKeyExtensionMiddleware splits a file extension off the object key, so a
+// request for /<key>.go loads <key>. The extension, without the dot, is stored
+// in the context. It must run after WithKeyRequiredMiddleware.
+func WithKeyExtensionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		key, ok := ObjectKeyFromContext(ctx)
+		if ok {
+			if i := strings.Index(key, "."); i > 0 {
+				ctx = WithKeyExtension(WithObjectKey(ctx, key[:i]), key[i+1:])
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type keyExtensionCtxKey struct{}
+
+// WithKeyExtension returns a copy of the parent context with the key extension
+// set.
+func WithKeyExtension(ctx context.Context, ext string) context.Context {
+	return context.WithValue(ctx, keyExtensionCtxKey{}, ext)
+}
+
+// KeyExtensionFromContext returns the key extension from the context, or an
+// empty string if the key had none.
+func KeyExtensionFromContext(ctx context.Context) string {
+	ext, _ := ctx.Value(keyExtensionCtxKey{}).(string)
+	return ext
+}
+
 type objectKeyCtxKey struct{}
 
 // WithObjectKey returns a copy of the parent context with the key set.
@@ -206,9 +240,9 @@ func TaggableFromContext(ctx context.Context) (Taggable, bool) {
 }
 
 // WithPreconditionsMiddleware checks for If-None-Match in the case of GET and If-Match
-// in the case of PUT and does a match against the object's ETag (modified date).
+// in the case of PUT and POST and does a match against the object's ETag (modified date).
 // If GET and the tag doesn't match, then the content is loaded; otherwise it
-// returns a 304. If PUT and the tag doesn't match, then a 412 is returned.
+// returns a 304. If PUT or POST and the tag doesn't match, then a 412 is returned.
 func WithPreconditionsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -232,7 +266,7 @@ func WithPreconditionsMiddleware(next http.Handler) http.Handler {
 					}
 				}
 			}
-		case http.MethodPut:
+		case http.MethodPut, http.MethodPost:
 			precondition := r.Header.Get("if-match")
 
 			if precondition != "" {
@@ -247,3 +281,48 @@ func WithPreconditionsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// WithMergeMiddleware lets edits that ask to be merged with ?merge=1 through
+// when their If-Match is stale, instead of failing them with 412. The stale
+// ETag is stored in the context as the merge base and the If-Match header is
+// dropped. It must run after withPreloaded and before
+// WithPreconditionsMiddleware.
+func WithMergeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		merge, err := boolFromQuery(r, "merge")
+		if err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		precondition := r.Header.Get("if-match")
+
+		to, ok := TaggableFromContext(r.Context())
+		if !merge || !ok || precondition == "" || to.ShouldWrite(precondition) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		r = r.WithContext(WithMergeBase(r.Context(), precondition))
+		r.Header.Del("if-match")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type mergeBaseCtxKey struct{}
+
+// WithMergeBase returns a copy of the parent context with the ETag of the
+// revision an edit was made to set.
+func WithMergeBase(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, mergeBaseCtxKey{}, etag)
+}
+
+// MergeBaseFromContext returns the ETag of the revision an edit was made to
+// if the edit has to be merged.
+func MergeBaseFromContext(ctx context.Context) (string, bool) {
+	etag, ok := ctx.Value(mergeBaseCtxKey{}).(string)
+	return etag, ok
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsHTML reports whether the client asked for an HTML response, which is
+// what browsers do. Command line clients like curl send */* and get the plain
+// response.
+func acceptsHTML(r *http.Request) bool {
+	return accepts(r, "text/html")
+}
+
+// accepts reports whether the client named mediaType in its accept header.
+// Wildcards don't count, and neither do types it gives a q value of 0.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range strings.Split(r.Header.Get("accept"), ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		if accepted == mediaType {
+			return acceptable(params["q"])
+		}
+	}
+
+	return false
+}
+
+// acceptsEncoding reports whether the client accepts responses in the content
+// coding encoding. Codings it gives a q value of 0 aren't accepted.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, accept := range strings.Split(r.Header.Get("accept-encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(accept), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+
+		return acceptable(qValue(params))
+	}
+
+	return false
+}
+
+// qValue returns the q parameter among the ; separated params of something
+// a client accepts, or "" if there isn't one.
+func qValue(params string) string {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(name), "q") {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// acceptable reports whether q, the q value a client gave something it
+// accepts, lets it be sent. Without one it's 1.
+func acceptable(q string) bool {
+	if q == "" {
+		return true
+	}
+
+	weight, err := strconv.ParseFloat(q, 64)
+
+	return err == nil && weight > 0
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccepts(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected bool
+	}{
+		{"text/html", true},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", true},
+		{"application/json, text/html;q=0.5", true},
+		{"*/*", false},
+		{"text/html;q=0", false},
+		{"text/html; q=0.0, */*", false},
+		{"text/html;q=nope", false},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", c.accept)
+
+		require.Equal(t, c.expected, acceptsHTML(r), c.accept)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected bool
+	}{
+		{"gzip", true},
+		{"br, GZIP;q=0.5", true},
+		{"gzip;level=9", true},
+		{"br", false},
+		{"br, gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"gzip;level=9;q=0", false},
+		{"gzip; level=9; Q=0.5", true},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", c.accept)
+
+		require.Equal(t, c.expected, acceptsEncoding(r, "gzip"), c.accept)
+	}
+}
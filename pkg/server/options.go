@@ -0,0 +1,183 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+// createOptionsFromRequest reads the optional object settings a client can
+// send when creating a jot or gallery.
+//
+// Objects are created under the key in the path when there is one, so
+// POST /txt/deploy-runbook creates the jot deploy-runbook.
+//
+// The lifetime of an object can be set with the ttl query parameter or the
+// Expires-In header, either as a Go duration (1h30m) or a number of seconds.
+// A jot can be made readable only once with the burn query parameter or the
+// Burn-After-Reading header.
+func createOptionsFromRequest(r *http.Request) (types.CreateOptions, error) {
+	var opts types.CreateOptions
+
+	opts.Key, _ = shiftPath(r.URL.Path)
+
+	ttl := r.URL.Query().Get("ttl")
+	if ttl == "" {
+		ttl = r.Header.Get("expires-in")
+	}
+
+	if ttl != "" {
+		d, err := parseTTL(ttl)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.ExpiresAt = time.Now().Add(d)
+	}
+
+	burn := r.URL.Query().Get("burn")
+	if burn == "" {
+		burn = r.Header.Get("burn-after-reading")
+	}
+
+	if burn != "" {
+		b, err := strconv.ParseBool(burn)
+		if err != nil {
+			return opts, errors.NewBadRequestError("invalid burn value: " + burn).WithCause(err)
+		}
+
+		opts.BurnAfterReading = b
+	}
+
+	return opts, nil
+}
+
+// formContentTypes are sent by clients like curl for any request body, so they
+// don't say anything about the content of a jot.
+var formContentTypes = map[string]bool{
+	"application/x-www-form-urlencoded": true,
+	"multipart/form-data":               true,
+}
+
+// jotOptionsFromRequest reads the create options of a jot, which on top of the
+// options of every object include the media type of the content and an
+// optional filename. The filename comes from the filename query parameter or
+// the Content-Disposition header. The encrypted query parameter marks content
+// the client encrypted itself.
+func jotOptionsFromRequest(r *http.Request) (types.CreateOptions, error) {
+	opts, err := createOptionsFromRequest(r)
+	if err != nil {
+		return opts, err
+	}
+
+	if ct := r.Header.Get("content-type"); ct != "" {
+		mediaType, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return opts, errors.NewBadRequestError("invalid content type: " + ct).WithCause(err)
+		}
+
+		if !formContentTypes[mediaType] {
+			// jots are text, so text without a charset is taken to be UTF-8
+			// like jots without a content type.
+			if strings.HasPrefix(mediaType, "text/") && params["charset"] == "" {
+				params["charset"] = "utf-8"
+			}
+
+			opts.ContentType = mime.FormatMediaType(mediaType, params)
+		}
+	}
+
+	if encrypted := r.URL.Query().Get("encrypted"); encrypted != "" {
+		b, err := strconv.ParseBool(encrypted)
+		if err != nil {
+			return opts, errors.NewBadRequestError("invalid encrypted value: " + encrypted).WithCause(err)
+		}
+
+		opts.Encrypted = b
+	}
+
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		if cd := r.Header.Get("content-disposition"); cd != "" {
+			_, params, err := mime.ParseMediaType(cd)
+			if err != nil {
+				return opts, errors.NewBadRequestError("invalid content disposition: " + cd).WithCause(err)
+			}
+
+			filename = params["filename"]
+		}
+	}
+
+	if filename != "" {
+		name, err := cleanFilename(filename)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Filename = name
+	}
+
+	return opts, nil
+}
+
+// cleanFilename drops any directories from name so it can be handed back to
+// clients that save it.
+func cleanFilename(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	if name == "." || name == "/" || name == ".." || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", errors.NewBadRequestError("invalid filename: " + name)
+	}
+
+	return name, nil
+}
+
+func parseTTL(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		secs, serr := strconv.Atoi(s)
+		if serr != nil {
+			return 0, errors.NewBadRequestError("invalid ttl: " + s).WithCause(err)
+		}
+
+		d = time.Duration(secs) * time.Second
+	}
+
+	if d <= 0 {
+		return 0, errors.NewBadRequestError("ttl must be positive: " + s)
+	}
+
+	return d, nil
+}
+
+// setContentDisposition sends the filename of a jot, asking the client to save
+// it rather than show it when download is set.
+func setContentDisposition(w http.ResponseWriter, jotFile *types.TextFile, download bool) {
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+
+	params := map[string]string{}
+	if jotFile.Filename != "" {
+		params["filename"] = jotFile.Filename
+	}
+
+	w.Header().Set("content-disposition", mime.FormatMediaType(disposition, params))
+}
+
+// setExpiresHeader tells the client when an object is going away, if ever.
+func setExpiresHeader(w http.ResponseWriter, meta types.ObjectMeta) {
+	if meta.ExpiresAt.IsZero() {
+		return
+	}
+
+	w.Header().Set("expires", meta.ExpiresAt.UTC().Format(http.TimeFormat))
+}
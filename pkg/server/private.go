@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/types"
+)
+
+// privateHandler serves the page that creates jots encrypted in the browser.
+type privateHandler struct{}
+
+func (h privateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+
+		return
+	}
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := privatePage().Render(r.Context(), w); err != nil {
+		log.Println(fmt.Errorf("error while rendering private page: %w", err))
+	}
+}
+
+// encrypted serves a jot the client encrypted. The server can't read it, so
+// browsers get a page that decrypts it with the key in the URL fragment and
+// everyone else gets the content as it was uploaded.
+func (h jotHandler) encrypted(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile) {
+	w.Header().Set("vary", "accept")
+
+	if !acceptsHTML(r) {
+		setStoredContentType(w, jotFile)
+		serveContent(w, r, jotFile.ModifiedDate, jotFile.Content)
+
+		return
+	}
+
+	blob, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to read jot").WithCause(err), w)
+
+		return
+	}
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := encryptedPage(jotFile.Key, string(blob)).Render(r.Context(), w); err != nil {
+		log.Println(fmt.Errorf("error while rendering encrypted page: %w", err))
+	}
+}
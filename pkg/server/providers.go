@@ -5,5 +5,7 @@ import "github.com/google/wire"
 var ProviderSet = wire.NewSet(
 	NewJotHandler,
 	NewImageHandler,
+	NewAdminHandler,
+	NewAPIHandler,
 	New,
 )
@@ -17,6 +17,8 @@ const (
 	// DefaultContentType is the default content type to use in responses that
 	// return the jot content
 	DefaultContentType = "text/plain; charset=utf-8"
+	// HTMLContentType is the content type of rendered pages
+	HTMLContentType = "text/html; charset=utf-8"
 )
 
 // Server listens to a port on an address as a HTTP server
@@ -25,11 +27,17 @@ type Server struct {
 	cfg        *config.Config
 	jotRoute   *jotHandler
 	imageRoute *imageHandler
+	adminRoute *adminHandler
+	apiRoute   *apiHandler
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var head string
 
+	if wantsJSON(r) {
+		w = jsonResponseWriter{w}
+	}
+
 	head, r.URL.Path = shiftPath(r.URL.Path)
 
 	var next http.Handler
@@ -39,6 +47,12 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		next = s.jotRoute
 	case "img":
 		next = s.imageRoute
+	case "admin":
+		next = s.adminRoute
+	case "api":
+		next = s.apiRoute
+	case "private":
+		next = privateHandler{}
 	case "favicon.ico":
 		next = faviconHandler{}
 	case "":
@@ -84,11 +98,13 @@ func (s *Server) run(srv *http.Server, errch chan<- error) {
 
 // New returns a new instance of a jot Server with
 // the data from the seedFile loaded.
-func New(cfg *config.Config, jr *jotHandler, ir *imageHandler) *Server {
+func New(cfg *config.Config, jr *jotHandler, ir *imageHandler, ar *adminHandler, api *apiHandler) *Server {
 	return &Server{
 		cfg:        cfg,
 		jotRoute:   jr,
 		imageRoute: ir,
+		adminRoute: ar,
+		apiRoute:   api,
 	}
 }
 
@@ -1,7 +1,12 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
@@ -12,16 +17,25 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cloudflare/gokey"
 	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
 	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/e2e"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
 	"github.com/kyleterry/jot/pkg/id"
 	pkgimage "github.com/kyleterry/jot/pkg/image"
 	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
 	"github.com/kyleterry/jot/pkg/jot"
+	"github.com/kyleterry/jot/pkg/jot/store/backends"
+	"github.com/kyleterry/jot/pkg/search"
 	"github.com/kyleterry/jot/pkg/store"
 	"github.com/kyleterry/jot/pkg/testutil"
 	"github.com/stretchr/testify/require"
@@ -29,7 +43,7 @@ import (
 
 const TestMasterPassword = "test password"
 
-func WithTestServer(t *testing.T, fn func(*httptest.Server)) {
+func WithTestServer(t *testing.T, fn func(*httptest.Server), configure ...func(*config.Config)) {
 	tmp, fs, cleanup := testutil.NewTextFilesystem(t)
 	defer cleanup()
 
@@ -44,6 +58,21 @@ func WithTestServer(t *testing.T, fn func(*httptest.Server)) {
 		DataDir:          config.DataDir(tmp),
 	}
 
+	for _, c := range configure {
+		c(cfg)
+	}
+
+	if cfg.Compression != "" {
+		blobs, err := blob.New(&blob.Options{StorageDir: cfg.DataDir, Compression: cfg.Compression})
+		require.NoError(t, err)
+
+		opts := backends.ProvideFilesystemOptions(cfg.DataDir)
+		opts.Path = tmp
+
+		fs, err = backends.NewFilesystem(opts, blobs)
+		require.NoError(t, err)
+	}
+
 	spec := auth.DefaultSpec()
 	sf, err := auth.NewSeedFile(cfg.MasterPassword, cfg.SeedFileLocation, spec)
 	require.NoError(t, err)
@@ -52,16 +81,22 @@ func WithTestServer(t *testing.T, fn func(*httptest.Server)) {
 	idm, err := id.NewIDManager()
 	require.NoError(t, err)
 
+	broker := events.NewBroker()
+	index := search.New("")
+
 	jotOpts := &store.Options{
 		PasswordManager: pm,
 		IDManager:       idm,
+		Events:          broker,
+		Search:          index,
 	}
 	textStore := jot.NewStore(fs, jotOpts)
 
-	jr := NewJotHandler(cfg, textStore, pm)
+	jr := NewJotHandler(cfg, textStore, pm, broker)
 	ir := NewImageHandler(cfg, nil, pm)
+	ar := NewAdminHandler(cfg, textStore, index)
 
-	srv := New(cfg, jr, ir)
+	srv := New(cfg, jr, ir, ar, NewAPIHandler(cfg, jr, ir))
 
 	ts := httptest.NewServer(srv)
 	defer ts.Close()
@@ -309,7 +344,7 @@ func TestJotServer(t *testing.T) {
 	})
 }
 
-func WithImageTestServer(t *testing.T, fn func(*httptest.Server)) {
+func WithImageTestServer(t *testing.T, fn func(*httptest.Server), configure ...func(*config.Config)) {
 	t.Helper()
 
 	tmp, err := os.MkdirTemp("", "github.com-kyleterry-jot-img")
@@ -327,6 +362,10 @@ func WithImageTestServer(t *testing.T, fn func(*httptest.Server)) {
 		DataDir:          config.DataDir(tmp),
 	}
 
+	for _, c := range configure {
+		c(cfg)
+	}
+
 	spec := auth.DefaultSpec()
 	sf, err := auth.NewSeedFile(cfg.MasterPassword, cfg.SeedFileLocation, spec)
 	require.NoError(t, err)
@@ -340,16 +379,20 @@ func WithImageTestServer(t *testing.T, fn func(*httptest.Server)) {
 		IDManager:       idm,
 	}
 
-	imgBackend, err := imagefs.New(&imagefs.Options{StorageDir: cfg.DataDir})
+	blobs, err := blob.New(&blob.Options{StorageDir: cfg.DataDir, Compression: cfg.Compression})
+	require.NoError(t, err)
+
+	imgBackend, err := imagefs.New(&imagefs.Options{StorageDir: cfg.DataDir, Blobs: blobs})
 	require.NoError(t, err)
 
 	imgStore := pkgimage.NewStore(imgBackend, storeOpts)
 
 	// txt is not exercised in image tests; lazy closures in NewJotHandler won't panic
-	jr := NewJotHandler(cfg, nil, pm)
+	jr := NewJotHandler(cfg, nil, pm, nil)
+	ar := NewAdminHandler(cfg, nil, nil)
 	ir := NewImageHandler(cfg, imgStore, pm)
 
-	srv := New(cfg, jr, ir)
+	srv := New(cfg, jr, ir, ar, NewAPIHandler(cfg, jr, ir))
 
 	ts := httptest.NewServer(srv)
 	defer ts.Close()
@@ -521,3 +564,1875 @@ func TestImageServer(t *testing.T) {
 		})
 	})
 }
+
+func TestJotExpiry(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		t.Run("POST with invalid ttl", func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/txt?ttl=soon", "text/plain", strings.NewReader("payload"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+
+		t.Run("GET before and after expiry", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/txt", strings.NewReader("payload"))
+			require.NoError(t, err)
+			req.Header.Set("Expires-In", "200ms")
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			jotURL := strings.TrimSpace(string(raw))
+
+			resp, err = client.Get(jotURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			expires, err := http.ParseTime(resp.Header.Get("Expires"))
+			require.NoError(t, err)
+			require.WithinDuration(t, time.Now(), expires, time.Minute)
+
+			time.Sleep(300 * time.Millisecond)
+
+			resp, err = client.Get(jotURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusGone, resp.StatusCode)
+		})
+	})
+}
+
+func TestJotBurnAfterReading(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		resp, err := client.Post(ts.URL+"/txt?burn=1", "text/plain", strings.NewReader("secret"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		jotURL := strings.TrimSpace(string(raw))
+
+		// bad query parameters are rejected before the jot is read, so they
+		// don't burn it.
+		for _, query := range []string{"?download=bogus", "?follow=bogus", "?render=bogus"} {
+			resp, err := client.Get(jotURL + query)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+
+		const readers = 8
+
+		var (
+			wg       sync.WaitGroup
+			statuses = make(chan int, readers)
+			bodies   = make(chan string, readers)
+		)
+
+		for range readers {
+			wg.Go(func() {
+				resp, err := client.Get(jotURL)
+				if err != nil {
+					statuses <- 0
+
+					return
+				}
+				defer resp.Body.Close()
+
+				b, _ := io.ReadAll(resp.Body)
+				statuses <- resp.StatusCode
+				bodies <- string(b)
+			})
+		}
+
+		wg.Wait()
+		close(statuses)
+		close(bodies)
+
+		var ok int
+		for status := range statuses {
+			if status == http.StatusOK {
+				ok++
+			} else {
+				require.Equal(t, http.StatusNotFound, status)
+			}
+		}
+		require.Equal(t, 1, ok)
+
+		var secrets int
+		for body := range bodies {
+			if body == "secret" {
+				secrets++
+			}
+		}
+		require.Equal(t, 1, secrets)
+
+		resp, err = client.Get(jotURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// createJot posts payload to the test server and returns the jot URL and password.
+func createJot(t *testing.T, ts *httptest.Server, path, payload string) (string, string) {
+	t.Helper()
+
+	resp, err := ts.Client().Post(ts.URL+path, "text/plain", strings.NewReader(payload))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return strings.TrimSpace(string(raw)), resp.Header.Get("Jot-Password")
+}
+
+func TestJotRevisions(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+
+		jotURL, password := createJot(t, ts, "/txt", "first")
+
+		req, err := http.NewRequest(http.MethodPut, jotURL, strings.NewReader("second"))
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+		t.Run("GET revisions", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/revisions")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			require.Len(t, lines, 2)
+			require.True(t, strings.HasPrefix(lines[0], "1 "))
+			require.True(t, strings.HasSuffix(lines[0], " 5"))
+			require.True(t, strings.HasPrefix(lines[1], "2 "))
+		})
+
+		t.Run("GET old revision", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/revisions/1")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "first", string(raw))
+		})
+
+		t.Run("GET missing revision", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/revisions/9")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+
+		t.Run("restore without password", func(t *testing.T) {
+			resp, err := client.Post(jotURL+"/revisions/1", "", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("restore", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, jotURL+"/revisions/1", nil)
+			require.NoError(t, err)
+			req.SetBasicAuth("", password)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+			resp, err = client.Get(jotURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "first", string(raw))
+
+			resp, err = client.Get(jotURL + "/revisions")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Len(t, strings.Split(strings.TrimSpace(string(raw)), "\n"), 3)
+		})
+	})
+}
+
+// updateJot replaces the content of a jot and fails the test if it isn't
+// accepted.
+func updateJot(t *testing.T, ts *httptest.Server, jotURL, password, payload string) {
+	t.Helper()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequest(http.MethodPut, jotURL, strings.NewReader(payload))
+	require.NoError(t, err)
+	req.SetBasicAuth("", password)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+}
+
+func TestJotDiff(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		jotURL, password := createJot(t, ts, "/txt", "one\ntwo\nthree\n")
+		updateJot(t, ts, jotURL, password, "one\n2\nthree\n")
+
+		t.Run("unified", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/diff?from=1&to=2")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "text/x-diff; charset=utf-8", resp.Header.Get("Content-Type"))
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(raw), "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n")
+		})
+
+		t.Run("side by side", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, jotURL+"/diff", nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(raw), `class="line ins"`)
+		})
+
+		t.Run("too different", func(t *testing.T) {
+			var a, b strings.Builder
+			for i := range 10000 {
+				fmt.Fprintf(&a, "a%d\n", i)
+				fmt.Fprintf(&b, "b%d\n", i)
+			}
+
+			jotURL, password := createJot(t, ts, "/txt", a.String())
+			updateJot(t, ts, jotURL, password, b.String())
+
+			resp, err := client.Get(jotURL + "/diff")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		})
+
+		t.Run("invalid revision", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/diff?from=zero")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+}
+
+func TestJotHighlight(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+		payload := "package main\n\nfunc main() {}\n"
+
+		jotURL, _ := createJot(t, ts, "/txt", payload)
+
+		get := func(t *testing.T, u, accept string) (*http.Response, string) {
+			req, err := http.NewRequest(http.MethodGet, u, nil)
+			require.NoError(t, err)
+
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return resp, string(raw)
+		}
+
+		t.Run("raw with extension", func(t *testing.T) {
+			resp, body := get(t, jotURL+".go", "*/*")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, DefaultContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, payload, body)
+		})
+
+		t.Run("browser without language", func(t *testing.T) {
+			resp, body := get(t, jotURL, "text/html")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, DefaultContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, payload, body)
+		})
+
+		t.Run("browser with extension", func(t *testing.T) {
+			resp, body := get(t, jotURL+".go", "text/html")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+			require.Contains(t, body, `<tr id="L3">`)
+			require.Contains(t, body, `<span class="kd">func</span>`)
+		})
+
+		t.Run("browser with lang", func(t *testing.T) {
+			resp, body := get(t, jotURL+"?lang=go", "text/html")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, body, "(Go)")
+		})
+	})
+}
+
+func TestJotMarkdown(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+		payload := "# Notes\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n<script>alert(1)</script>\n"
+
+		jotURL, _ := createJot(t, ts, "/txt", payload)
+
+		t.Run("render query", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "?render=markdown")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(raw), `<h1 id="notes">`)
+			require.Contains(t, string(raw), "<table>")
+			require.NotContains(t, string(raw), "<script>alert")
+		})
+
+		t.Run("md extension in a browser", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, jotURL+".md", nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/html")
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+		})
+
+		t.Run("md extension with curl", func(t *testing.T) {
+			resp, err := client.Get(jotURL + ".md")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, payload, string(raw))
+		})
+
+		t.Run("unknown render mode", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "?render=rst")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+}
+
+func TestJotAppend(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		jotURL, password := createJot(t, ts, "/txt", "line 1\n")
+
+		resp, err := client.Get(jotURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+
+		appendReq := func(t *testing.T, password, ifMatch, payload string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, jotURL+"/append", strings.NewReader(payload))
+			require.NoError(t, err)
+
+			if password != "" {
+				req.SetBasicAuth("", password)
+			}
+
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			return resp
+		}
+
+		t.Run("without password", func(t *testing.T) {
+			resp := appendReq(t, "", "", "line 2\n")
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("with stale If-Match", func(t *testing.T) {
+			resp := appendReq(t, password, "2001-01-01T00:00:00Z", "line 2\n")
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		})
+
+		t.Run("with current If-Match", func(t *testing.T) {
+			resp := appendReq(t, password, etag, "line 2\n")
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+			require.NotEmpty(t, resp.Header.Get("ETag"))
+			require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+			resp = appendReq(t, password, "", "line 3\n")
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		})
+
+		t.Run("GET after append", func(t *testing.T) {
+			resp, err := client.Get(jotURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "line 1\nline 2\nline 3\n", string(raw))
+		})
+	})
+}
+
+// appendJot adds payload to the end of a jot and fails the test if it isn't
+// accepted.
+func appendJot(t *testing.T, ts *httptest.Server, jotURL, password, payload string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, jotURL+"/append", strings.NewReader(payload))
+	require.NoError(t, err)
+	req.SetBasicAuth("", password)
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+// readEvent reads one server-sent event and returns its name and data.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var (
+		event string
+		data  []string
+	)
+
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			return event, strings.Join(data, "\n")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestJotEvents(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		jotURL, password := createJot(t, ts, "/txt", "line 1\n")
+
+		resp, err := ts.Client().Get(jotURL + "/events")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		events := bufio.NewReader(resp.Body)
+
+		appendJot(t, ts, jotURL, password, "line 2\n")
+
+		event, data := readEvent(t, events)
+		require.Equal(t, "append", event)
+		require.Equal(t, "line 2\n", data)
+
+		updateJot(t, ts, jotURL, password, "replaced\n")
+
+		event, data = readEvent(t, events)
+		require.Equal(t, "revision", event)
+		require.True(t, strings.HasPrefix(data, "2 "))
+		require.True(t, strings.HasSuffix(data, " 9"))
+
+		req, err := http.NewRequest(http.MethodDelete, jotURL, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		dresp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		dresp.Body.Close()
+
+		event, _ = readEvent(t, events)
+		require.Equal(t, "delete", event)
+	})
+}
+
+func TestJotFollow(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		jotURL, password := createJot(t, ts, "/txt", "line 1\n")
+
+		resp, err := ts.Client().Get(jotURL + "?follow=1")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := bufio.NewReader(resp.Body)
+
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "line 1\n", line)
+
+		appendJot(t, ts, jotURL, password, "line 2\n")
+
+		line, err = body.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "line 2\n", line)
+
+		// replacing the content with something else ends the stream
+		updateJot(t, ts, jotURL, password, "something else\n")
+
+		rest, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.Empty(t, rest)
+	})
+}
+
+func TestJotLiveUpload(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		upload, uploadWriter := io.Pipe()
+
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/txt", upload)
+		require.NoError(t, err)
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		// the jot exists before any of the content has been sent
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get("Jot-Password"))
+
+		jotURL := resp.Header.Get("Location")
+		require.NotEmpty(t, jotURL)
+
+		_, err = io.WriteString(uploadWriter, "line 1\n")
+		require.NoError(t, err)
+
+		rresp, err := ts.Client().Get(jotURL)
+		require.NoError(t, err)
+		defer rresp.Body.Close()
+
+		require.Equal(t, http.StatusOK, rresp.StatusCode)
+
+		body := bufio.NewReader(rresp.Body)
+
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "line 1\n", line)
+
+		_, err = io.WriteString(uploadWriter, "line 2\n")
+		require.NoError(t, err)
+
+		line, err = body.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "line 2\n", line)
+
+		_, err = io.WriteString(uploadWriter, "done")
+		require.NoError(t, err)
+		require.NoError(t, uploadWriter.Close())
+
+		// the reader's response ends with the upload
+		rest, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.Equal(t, "done", string(rest))
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, jotURL, strings.TrimSpace(string(raw)))
+
+		// once the upload is over the jot is served like any other
+		rresp, err = ts.Client().Get(jotURL)
+		require.NoError(t, err)
+		defer rresp.Body.Close()
+
+		raw, err = io.ReadAll(rresp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "line 1\nline 2\ndone", string(raw))
+		require.NotEmpty(t, rresp.Header.Get("Etag"))
+	})
+}
+
+func TestJotContentType(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		resp, err := client.Post(ts.URL+"/txt?filename=notes/todo.md", "text/markdown; charset=utf-8", strings.NewReader("# todo\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		jotURL := resp.Header.Get("Location")
+
+		resp, err = client.Get(jotURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, "text/markdown; charset=utf-8", resp.Header.Get("Content-Type"))
+		require.Equal(t, "sandbox", resp.Header.Get("Content-Security-Policy"))
+		require.Equal(t, `inline; filename=todo.md`, resp.Header.Get("Content-Disposition"))
+
+		resp, err = client.Get(jotURL + "?download=1")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, `attachment; filename=todo.md`, resp.Header.Get("Content-Disposition"))
+
+		// form encoded bodies, which is what curl sends by default, are served
+		// as plain text.
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/txt", strings.NewReader("payload"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Content-Disposition", `attachment; filename="payload.txt"`)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		resp, err = client.Get(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+		require.Empty(t, resp.Header.Get("Content-Security-Policy"))
+		require.Equal(t, `inline; filename=payload.txt`, resp.Header.Get("Content-Disposition"))
+
+		resp, err = client.Post(ts.URL+"/txt", "not a media type", strings.NewReader("payload"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUploadLimits(t *testing.T) {
+	limits := func(cfg *config.Config) {
+		cfg.MaxTextSize = 8
+		cfg.MaxImageSize = 1 << 10
+		cfg.MaxImages = 1
+		cfg.MaxRequestSize = 64 << 10
+	}
+
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		resp, err := client.Post(ts.URL+"/txt", "text/plain", strings.NewReader("too large"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		// bodies without a length are cut off while they're read
+		resp, err = client.Post(ts.URL+"/txt", "text/plain", io.MultiReader(strings.NewReader("too large")))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		jotURL, password := createJot(t, ts, "/txt", "small")
+
+		req, err := http.NewRequest(http.MethodPost, jotURL+"/append", io.MultiReader(strings.NewReader("too large")))
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		// the partial append is cut off again
+		resp, err = client.Get(jotURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "small", string(raw))
+
+		// appends that each fit can't grow the jot past the limit together
+		appendJot := func(content string) int {
+			req, err := http.NewRequest(http.MethodPost, jotURL+"/append", strings.NewReader(content))
+			require.NoError(t, err)
+			req.SetBasicAuth("", password)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			return resp.StatusCode
+		}
+
+		require.Equal(t, http.StatusNoContent, appendJot("abc"))
+		require.Equal(t, http.StatusRequestEntityTooLarge, appendJot("d"))
+	}, limits)
+
+	WithImageTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		body, ct := imageMultipart(t, "large.png", bytes.Repeat([]byte{0}, 2<<10))
+
+		resp, err := client.Post(ts.URL+"/img", ct, body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+
+		for _, name := range []string{"one.png", "two.png"} {
+			fw, err := mw.CreateFormFile("images", name)
+			require.NoError(t, err)
+
+			_, err = fw.Write(minimalPNG(t))
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, mw.Close())
+
+		resp, err = client.Post(ts.URL+"/img", mw.FormDataContentType(), &buf)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		body, ct = imageMultipart(t, "huge.png", bytes.Repeat([]byte{0}, 128<<10))
+
+		resp, err = client.Post(ts.URL+"/img", ct, body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}, limits)
+}
+
+func TestJotEncrypted(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		blob, key, err := e2e.Encrypt([]byte("top secret"))
+		require.NoError(t, err)
+
+		resp, err := client.Post(ts.URL+"/txt?encrypted=1", "application/json", bytes.NewReader(blob))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		jotURL := resp.Header.Get("Location")
+		password := resp.Header.Get("Jot-Password")
+
+		// clients get the blob back untouched, even when they ask for it
+		// to be rendered.
+		resp, err = client.Get(jotURL + ".md")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, blob, raw)
+
+		content, err := e2e.Decrypt(raw, key)
+		require.NoError(t, err)
+		require.Equal(t, "top secret", string(content))
+
+		// browsers get a page that decrypts it
+		req, err := http.NewRequest(http.MethodGet, jotURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+
+		raw, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(raw), "data-blob=")
+		require.NotContains(t, string(raw), "top secret")
+
+		req, err = http.NewRequest(http.MethodPost, jotURL+"/append", strings.NewReader("more"))
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Get(ts.URL + "/private")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+	})
+}
+
+func TestJotCompression(t *testing.T) {
+	compress := func(cfg *config.Config) {
+		cfg.Compression = "gzip"
+	}
+
+	payload := strings.Repeat("a line of a log file\n", 100)
+
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		jotURL, _ := createJot(t, ts, "/txt", payload)
+
+		// asking for gzip turns off the transport's own decompression, so the
+		// body is what the server sent.
+		req, err := http.NewRequest(http.MethodGet, jotURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("accept-encoding", "gzip")
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "gzip", resp.Header.Get("content-encoding"))
+		require.Contains(t, resp.Header.Values("vary"), "accept-encoding")
+		require.Less(t, resp.ContentLength, int64(len(payload)))
+
+		encodedETag := resp.Header.Get("etag")
+
+		gz, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+
+		raw, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(raw))
+
+		// clients that don't accept the compression get the content as is
+		req.Header.Set("accept-encoding", "br, gzip;q=0")
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Empty(t, resp.Header.Get("content-encoding"))
+		require.Contains(t, resp.Header.Values("vary"), "accept-encoding")
+
+		// the compressed body is another representation, so it has another
+		// ETag, which still works for conditional requests.
+		etag := resp.Header.Get("etag")
+		require.NotEmpty(t, etag)
+		require.NotEqual(t, etag, encodedETag)
+
+		raw, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(raw))
+
+		for _, tag := range []string{etag, encodedETag} {
+			req, err := http.NewRequest(http.MethodGet, jotURL, nil)
+			require.NoError(t, err)
+			req.Header.Set("if-none-match", tag)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNotModified, resp.StatusCode)
+		}
+
+		// codings the server doesn't send don't make up an ETag of the jot
+		other, err := http.NewRequest(http.MethodGet, jotURL, nil)
+		require.NoError(t, err)
+		other.Header.Set("if-none-match", etag+"-br")
+
+		resp, err = client.Do(other)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// ranges are of the decompressed content
+		req.Header.Set("accept-encoding", "gzip")
+		req.Header.Set("range", "bytes=0-5")
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("content-encoding"))
+
+		raw, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "a line", string(raw))
+	}, compress)
+
+	WithImageTestServer(t, func(ts *httptest.Server) {
+		body, ct := imageMultipart(t, "image.png", minimalPNG(t))
+
+		resp, err := ts.Client().Post(ts.URL+"/img", ct, body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		resp, err = ts.Client().Get(strings.TrimSpace(string(raw)) + "/image.png")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		raw, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, minimalPNG(t), raw)
+	}, compress)
+}
+
+func TestImageDedup(t *testing.T) {
+	var dataDir config.DataDir
+
+	blobFiles := func() []string {
+		paths, err := filepath.Glob(filepath.Join(string(dataDir), config.BlobDirectoryName, "*", "*"))
+		require.NoError(t, err)
+
+		var blobs []string
+
+		for _, path := range paths {
+			if !strings.HasSuffix(path, ".refs") {
+				blobs = append(blobs, path)
+			}
+		}
+
+		return blobs
+	}
+
+	WithImageTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		upload := func() (string, string) {
+			body, ct := imageMultipart(t, "image.png", minimalPNG(t))
+
+			resp, err := client.Post(ts.URL+"/img", ct, body)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return strings.TrimSpace(string(raw)), resp.Header.Get("Jot-Password")
+		}
+
+		first, password := upload()
+		second, _ := upload()
+
+		// the same image uploaded twice is stored once
+		require.Len(t, blobFiles(), 1)
+
+		req, err := http.NewRequest(http.MethodDelete, first, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		// the other gallery still has it
+		require.Len(t, blobFiles(), 1)
+
+		resp, err = client.Get(second + "/image.png")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, minimalPNG(t), raw)
+	}, func(cfg *config.Config) {
+		dataDir = cfg.DataDir
+	})
+}
+
+func TestAdminSearch(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		searchReq := func(t *testing.T, password, query string) *http.Response {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/admin/search?q="+url.QueryEscape(query), nil)
+			require.NoError(t, err)
+
+			if password != "" {
+				req.SetBasicAuth("", password)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		first, password := createJot(t, ts, "/txt", "the quick brown fox\njumps over\nthe lazy dog\n")
+		second, _ := createJot(t, ts, "/txt", "a lazy afternoon\n")
+
+		resp, err := client.Post(ts.URL+"/txt?burn=1", "text/plain", strings.NewReader("lazy secret\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		t.Run("wrong password", func(t *testing.T) {
+			resp := searchReq(t, "nope", "lazy")
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("no query", func(t *testing.T) {
+			resp := searchReq(t, "admin", "  ")
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+
+		t.Run("hits", func(t *testing.T) {
+			resp := searchReq(t, "admin", "LAZY")
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			require.Len(t, lines, 4)
+
+			// newest first, and jots that burn after reading are never shown
+			require.True(t, strings.HasPrefix(lines[0], second+" "))
+			require.Equal(t, "  1: a lazy afternoon", lines[1])
+			require.True(t, strings.HasPrefix(lines[2], first+" "))
+			require.Equal(t, "  3: the lazy dog", lines[3])
+		})
+
+		t.Run("every word", func(t *testing.T) {
+			resp := searchReq(t, "admin", "lazy fox")
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, first+" ", string(raw)[:len(first)+1])
+			require.Contains(t, string(raw), "  1: the quick brown fox\n")
+		})
+
+		t.Run("after update", func(t *testing.T) {
+			updateJot(t, ts, first, password, "nothing to see here\n")
+
+			resp := searchReq(t, "admin", "fox")
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Empty(t, raw)
+		})
+
+		t.Run("after delete", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, first, nil)
+			require.NoError(t, err)
+			req.SetBasicAuth("", password)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			resp = searchReq(t, "admin", "nothing")
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Empty(t, raw)
+		})
+	}, func(cfg *config.Config) {
+		cfg.AdminPassword = "admin"
+	})
+
+	t.Run("without an admin password", func(t *testing.T) {
+		WithTestServer(t, func(ts *httptest.Server) {
+			resp, err := ts.Client().Get(ts.URL + "/admin/search?q=lazy")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+	})
+}
+
+func TestVanityKeys(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		post := func(t *testing.T, path, payload string) *http.Response {
+			resp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader(payload))
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		resp := post(t, "/txt/deploy-runbook", "step 1\n")
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, ts.URL+"/txt/deploy-runbook", resp.Header.Get("Location"))
+
+		password := resp.Header.Get("Jot-Password")
+		require.NotEmpty(t, password)
+
+		t.Run("GET", func(t *testing.T) {
+			resp, err := client.Get(ts.URL + "/txt/deploy-runbook")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "step 1\n", string(raw))
+		})
+
+		t.Run("taken", func(t *testing.T) {
+			resp := post(t, "/txt/deploy-runbook", "step 2\n")
+			resp.Body.Close()
+			require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+			resp, err := client.Get(ts.URL + "/txt/deploy-runbook")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "step 1\n", string(raw))
+		})
+
+		t.Run("password", func(t *testing.T) {
+			updateJot(t, ts, ts.URL+"/txt/deploy-runbook", password, "step 1\nstep 2\n")
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for _, key := range []string{"ab", strings.Repeat("a", 65), "has%20space", "emoji%F0%9F%98%80", "dots.md"} {
+				resp := post(t, "/txt/"+key, "content")
+				resp.Body.Close()
+				require.Equal(t, http.StatusBadRequest, resp.StatusCode, key)
+			}
+		})
+
+		t.Run("live", func(t *testing.T) {
+			resp := post(t, "/txt/live-notes?live", "streamed")
+			resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+			require.Equal(t, ts.URL+"/txt/live-notes", resp.Header.Get("Location"))
+		})
+	})
+
+	WithImageTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		upload := func() *http.Response {
+			body, ct := imageMultipart(t, "image.png", minimalPNG(t))
+
+			resp, err := client.Post(ts.URL+"/img/team-photo", ct, body)
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		resp := upload()
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, ts.URL+"/img/team-photo", resp.Header.Get("Location"))
+
+		password := resp.Header.Get("Jot-Password")
+
+		resp = upload()
+		resp.Body.Close()
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp, err := client.Get(ts.URL + "/img/team-photo/image.png")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		req, err := http.NewRequest(http.MethodDelete, ts.URL+"/img/team-photo", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", password)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestJotFork(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		resp, err := client.Post(ts.URL+"/txt?ttl=1h", "text/x-go", strings.NewReader("package main\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		parentURL := resp.Header.Get("Location")
+		parentPassword := resp.Header.Get("Jot-Password")
+
+		resp, err = client.Post(parentURL+"/fork", "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		forkURL := resp.Header.Get("Location")
+		forkPassword := resp.Header.Get("Jot-Password")
+		require.NotEqual(t, parentURL, forkURL)
+		require.NotEmpty(t, forkPassword)
+		require.NotEqual(t, parentPassword, forkPassword)
+
+		t.Run("GET", func(t *testing.T) {
+			resp, err := client.Get(forkURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, "<"+parentURL+`>; rel="fork-of"`, resp.Header.Get("Link"))
+			require.Equal(t, "text/x-go; charset=utf-8", resp.Header.Get("Content-Type"))
+			require.NotEmpty(t, resp.Header.Get("Expires"))
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "package main\n", string(raw))
+
+			resp, err = client.Get(parentURL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Empty(t, resp.Header.Get("Link"))
+		})
+
+		t.Run("edit", func(t *testing.T) {
+			updateJot(t, ts, forkURL, forkPassword, "package fork\n")
+
+			resp, err := client.Get(parentURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "package main\n", string(raw))
+		})
+
+		t.Run("burn after reading", func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/txt?burn=1", "text/plain", strings.NewReader("secret"))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			burnURL := resp.Header.Get("Location")
+
+			resp, err = client.Post(burnURL+"/fork", "", nil)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			// the jot is still there to be read once
+			resp, err = client.Get(burnURL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/txt/nope/fork", "", nil)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+	})
+}
+
+func TestJotMerge(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+
+		jotURL, password := createJot(t, ts, "/txt", "a\nb\nc\n")
+
+		get := func(t *testing.T) (string, string) {
+			resp, err := client.Get(jotURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return string(raw), resp.Header.Get("ETag")
+		}
+
+		put := func(t *testing.T, query, ifMatch, payload string) *http.Response {
+			req, err := http.NewRequest(http.MethodPut, jotURL+query, strings.NewReader(payload))
+			require.NoError(t, err)
+			req.SetBasicAuth("", password)
+			req.Header.Set("If-Match", ifMatch)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		_, base := get(t)
+
+		resp := put(t, "", base, "A\nb\nc\n")
+		resp.Body.Close()
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+		t.Run("without merge", func(t *testing.T) {
+			resp := put(t, "", base, "a\nb\nC\n")
+			resp.Body.Close()
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		})
+
+		t.Run("clean", func(t *testing.T) {
+			resp := put(t, "?merge=1", base, "a\nb\nC\n")
+			resp.Body.Close()
+			require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+			content, _ := get(t)
+			require.Equal(t, "A\nb\nC\n", content)
+		})
+
+		t.Run("conflict", func(t *testing.T) {
+			current, etag := get(t)
+
+			resp := put(t, "?merge=1", base, "a\nb\nc!\n")
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusConflict, resp.StatusCode)
+			require.Equal(t, etag, resp.Header.Get("ETag"))
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "A\nb\n<<<<<<< current\nC\n=======\nc!\n>>>>>>> yours\n", string(raw))
+
+			// nothing is stored until the conflict is resolved
+			content, _ := get(t)
+			require.Equal(t, current, content)
+
+			resp = put(t, "?merge=1", etag, "A\nb\nC!\n")
+			resp.Body.Close()
+			require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+			content, _ = get(t)
+			require.Equal(t, "A\nb\nC!\n", content)
+		})
+
+		t.Run("unknown base", func(t *testing.T) {
+			resp := put(t, "?merge=1", "2001-01-01T00:00:00Z", "whatever\n")
+			resp.Body.Close()
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		})
+	})
+}
+
+func TestJotEdit(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+		jotURL, password := createJot(t, ts, "/txt", "hello\n")
+
+		openStream := func(password string) *http.Response {
+			req, err := http.NewRequest(http.MethodGet, jotURL+"/edit", nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/event-stream")
+			req.SetBasicAuth("", password)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		sendOp := func(body string) int {
+			req, err := http.NewRequest(http.MethodPost, jotURL+"/edit", strings.NewReader(body))
+			require.NoError(t, err)
+			req.SetBasicAuth("", password)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			return resp.StatusCode
+		}
+
+		t.Run("page", func(t *testing.T) {
+			resp, err := client.Get(jotURL + "/edit")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+		})
+
+		t.Run("wrong password", func(t *testing.T) {
+			resp := openStream("wrong")
+			resp.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("nobody editing", func(t *testing.T) {
+			require.Equal(t, http.StatusBadRequest, sendOp(`{"version":0,"op":[6]}`))
+		})
+
+		t.Run("edit", func(t *testing.T) {
+			resp := openStream(password)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			events := bufio.NewReader(resp.Body)
+
+			event, data := readEvent(t, events)
+			require.Equal(t, "snapshot", event)
+			require.JSONEq(t, `{"version":0,"text":"hello\n"}`, data)
+
+			require.Equal(t, http.StatusNoContent, sendOp(`{"client":"a","version":0,"op":[5," world",1]}`))
+
+			event, data = readEvent(t, events)
+			require.Equal(t, "op", event)
+			require.JSONEq(t, `{"version":1,"op":[5," world",1],"client":"a"}`, data)
+
+			// made without seeing the first change, so it's transformed
+			// against it.
+			require.Equal(t, http.StatusNoContent, sendOp(`{"client":"b","version":0,"op":[-5,"bye",1]}`))
+
+			event, data = readEvent(t, events)
+			require.Equal(t, "op", event)
+			require.JSONEq(t, `{"version":2,"op":[-5,"bye",7],"client":"b"}`, data)
+
+			require.Equal(t, http.StatusBadRequest, sendOp(`{"client":"a","version":5,"op":[10]}`))
+
+			// the last editor leaving saves the jot
+			resp.Body.Close()
+
+			require.Eventually(t, func() bool {
+				resp, err := client.Get(jotURL)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				raw, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				return string(raw) == "bye world\n"
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+
+		t.Run("burn after reading", func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/txt?burn=1", "text/plain", strings.NewReader("secret"))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			req, err := http.NewRequest(http.MethodGet, resp.Header.Get("Location")+"/edit", nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/event-stream")
+			req.SetBasicAuth("", resp.Header.Get("Jot-Password"))
+
+			resp, err = client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+}
+
+func TestWebUI(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		getHTML := func(url string) (*http.Response, string) {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return resp, string(raw)
+		}
+
+		t.Run("home", func(t *testing.T) {
+			resp, body := getHTML(ts.URL + "/")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, HTMLContentType, resp.Header.Get("Content-Type"))
+			require.Contains(t, body, `<form id="create">`)
+			require.Contains(t, body, `id="drop"`)
+
+			resp, err := client.Get(ts.URL + "/")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(raw), "Jot version"))
+		})
+
+		t.Run("edit form", func(t *testing.T) {
+			jotURL, _ := createJot(t, ts, "/txt", "a < b\n")
+
+			jresp, err := client.Get(jotURL)
+			require.NoError(t, err)
+			jresp.Body.Close()
+
+			resp, body := getHTML(jotURL + "/edit?form=1")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, body, `data-etag="`+jresp.Header.Get("ETag")+`"`)
+			require.Contains(t, body, "a &lt; b\n</textarea>")
+		})
+
+		t.Run("burn after reading", func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/txt?burn=1", "text/plain", strings.NewReader("secret"))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			jotURL := resp.Header.Get("Location")
+
+			resp, _ = getHTML(jotURL + "/edit?form=1")
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			resp, body := getHTML(jotURL)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, body, "secret")
+		})
+	})
+}
+
+func TestJSONResponses(t *testing.T) {
+	doJSON := func(t *testing.T, client *http.Client, method, url, password string, body io.Reader, header http.Header) (*http.Response, map[string]any) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, url, body)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var v map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+
+		return resp, v
+	}
+
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		resp, created := doJSON(t, client, http.MethodPost, ts.URL+"/txt", "", strings.NewReader("hello\n"), nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, JSONContentType, resp.Header.Get("Content-Type"))
+		require.Equal(t, resp.Header.Get("Location"), created["url"])
+		require.Equal(t, resp.Header.Get("Jot-Password"), created["password"])
+		require.NotEmpty(t, created["key"])
+
+		jotURL, password := created["url"].(string), created["password"].(string)
+
+		resp, jot := doJSON(t, client, http.MethodGet, jotURL, "", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, created["key"], jot["key"])
+		require.Equal(t, "hello\n", jot["content"])
+		require.EqualValues(t, 6, jot["size"])
+		require.Equal(t, resp.Header.Get("ETag"), jot["etag"])
+		require.NotEmpty(t, jot["modified"])
+
+		t.Run("PUT", func(t *testing.T) {
+			header := http.Header{"If-Match": {jot["etag"].(string)}}
+
+			resp, updated := doJSON(t, client, http.MethodPut, jotURL, password, strings.NewReader("hello world\n"), header)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.EqualValues(t, 12, updated["size"])
+			require.NotEqual(t, jot["etag"], updated["etag"])
+			require.Equal(t, resp.Header.Get("ETag"), updated["etag"])
+			require.Nil(t, updated["content"])
+
+			// the ETag is stale now
+			resp, problem := doJSON(t, client, http.MethodPut, jotURL, password, strings.NewReader("again\n"), header)
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+			require.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, "etag_mismatch", problem["code"])
+			require.EqualValues(t, http.StatusPreconditionFailed, problem["status"])
+		})
+
+		t.Run("errors", func(t *testing.T) {
+			resp, problem := doJSON(t, client, http.MethodDelete, jotURL, "wrong", nil, nil)
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			require.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, "invalid_password", problem["code"])
+			require.Equal(t, "invalid password", problem["detail"])
+			require.Equal(t, "Unauthorized", problem["title"])
+		})
+
+		t.Run("DELETE", func(t *testing.T) {
+			resp, deleted := doJSON(t, client, http.MethodDelete, jotURL, password, nil, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, created["key"], deleted["key"])
+
+			resp, problem := doJSON(t, client, http.MethodGet, jotURL, "", nil, nil)
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+			require.Equal(t, "not_found", problem["code"])
+		})
+	})
+
+	WithImageTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		body, contentType := imageMultipart(t, "pixel.png", minimalPNG(t))
+
+		resp, created := doJSON(t, client, http.MethodPost, ts.URL+"/img", "", body, http.Header{"Content-Type": {contentType}})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		galleryURL, password := created["url"].(string), created["password"].(string)
+
+		resp, gallery := doJSON(t, client, http.MethodGet, galleryURL, "", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, created["key"], gallery["key"])
+		require.Equal(t, []any{map[string]any{
+			"name":         "pixel.png",
+			"url":          galleryURL + "/pixel.png",
+			"content_type": "image/png",
+		}}, gallery["images"])
+
+		resp, deleted := doJSON(t, client, http.MethodDelete, galleryURL, password, nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, created["key"], deleted["key"])
+	})
+}
+
+func TestAPISpec(t *testing.T) {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas struct {
+				Problem struct {
+					Properties struct {
+						Code struct {
+							Enum []string `json:"enum"`
+						} `json:"code"`
+					} `json:"properties"`
+				} `json:"Problem"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	var documented []string
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	var registered []string
+
+	for _, route := range NewAPIHandler(&config.Config{}, nil, nil).routes {
+		registered = append(registered, route.method+" "+route.pattern)
+	}
+
+	require.ElementsMatch(t, registered, documented)
+
+	for _, code := range spec.Components.Schemas.Problem.Properties.Code.Enum {
+		require.Equal(t, code, errors.ErrorTypeFromString(code).String())
+	}
+}
+
+func TestAPI(t *testing.T) {
+	WithTestServer(t, func(ts *httptest.Server) {
+		client := ts.Client()
+
+		do := func(method, path, password, contentType, body string, header http.Header) (*http.Response, map[string]any) {
+			t.Helper()
+
+			req, err := http.NewRequest(method, ts.URL+"/api/v1"+path, strings.NewReader(body))
+			require.NoError(t, err)
+
+			for k, v := range header {
+				req.Header[k] = v
+			}
+
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+
+			if password != "" {
+				req.SetBasicAuth("", password)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var v map[string]any
+			if resp.StatusCode != http.StatusNoContent {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+			}
+
+			return resp, v
+		}
+
+		resp, spec := do(http.MethodGet, "/openapi.json", "", "", "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "3.0.3", spec["openapi"])
+
+		resp, created := do(http.MethodPost, "/txt", "", JSONContentType,
+			`{"content":"package main\n","content_type":"text/x-go","ttl":"1h"}`, nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		key, password := created["key"].(string), created["password"].(string)
+		require.Equal(t, ts.URL+"/txt/"+key, created["url"])
+
+		resp, jot := do(http.MethodGet, "/txt/"+key, "", "", "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "package main\n", jot["content"])
+		require.Equal(t, "text/x-go; charset=utf-8", jot["content_type"])
+		require.NotEmpty(t, jot["expires"])
+
+		resp, updated := do(http.MethodPut, "/txt/"+key, password, JSONContentType,
+			`{"content":"package jot\n"}`, http.Header{"If-Match": {jot["etag"].(string)}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.EqualValues(t, 12, updated["size"])
+
+		resp, _ = do(http.MethodPost, "/txt/"+key+"/append", password, JSONContentType, `{"content":"// more\n"}`, nil)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, jot = do(http.MethodGet, "/txt/"+key, "", "", "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "package jot\n// more\n", jot["content"])
+		require.Nil(t, jot["content_encoding"])
+
+		t.Run("base64", func(t *testing.T) {
+			content := []byte{0xff, 0xfe, 0x00, 'j', 'o', 't', 0x80}
+
+			resp, created := do(http.MethodPost, "/txt", "", JSONContentType,
+				`{"content":"`+base64.StdEncoding.EncodeToString(content)+`","content_encoding":"base64"}`, nil)
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			resp, err := client.Get(created["url"].(string))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, content, raw)
+
+			resp, jot := do(http.MethodGet, "/txt/"+created["key"].(string), "", "", "", nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "base64", jot["content_encoding"])
+			require.Equal(t, base64.StdEncoding.EncodeToString(content), jot["content"])
+		})
+
+		t.Run("errors", func(t *testing.T) {
+			cases := []struct {
+				name                string
+				method, path, body  string
+				contentType, status string
+				code                string
+			}{
+				{"unknown route", http.MethodGet, "/nope", "", "", "404", "not_found"},
+				{"unknown jot", http.MethodGet, "/txt/missing", "", "", "404", "not_found"},
+				{"method", http.MethodPatch, "/txt/" + key, "", "", "405", "method_not_allowed"},
+				{"raw body", http.MethodPost, "/txt", "hello", "text/plain", "415", "unsupported_format"},
+				{"invalid body", http.MethodPost, "/txt", "{", JSONContentType, "400", "bad_request"},
+				{"invalid base64", http.MethodPost, "/txt", `{"content":"!","content_encoding":"base64"}`, JSONContentType, "400", "bad_request"},
+				{"content encoding", http.MethodPost, "/txt", `{"content":"","content_encoding":"hex"}`, JSONContentType, "400", "bad_request"},
+				{"password", http.MethodDelete, "/txt/" + key, "", "", "401", "invalid_password"},
+			}
+
+			for _, c := range cases {
+				t.Run(c.name, func(t *testing.T) {
+					resp, problem := do(c.method, c.path, "wrong", c.contentType, c.body, nil)
+					require.Equal(t, c.status, strconv.Itoa(resp.StatusCode))
+					require.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+					require.Equal(t, c.code, problem["code"])
+				})
+			}
+		})
+
+		resp, deleted := do(http.MethodDelete, "/txt/"+key, password, "", "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, key, deleted["key"])
+	})
+}
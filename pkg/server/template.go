@@ -3,7 +3,7 @@ package server
 import (
 	"bytes"
 	_ "embed"
-	"net/http"
+	"io"
 	"text/template"
 )
 
@@ -18,7 +18,7 @@ type IndexTemplateContext struct {
 	Host    string
 }
 
-func render(w http.ResponseWriter, content string, tplCtx interface{}) error {
+func render(w io.Writer, content string, tplCtx interface{}) error {
 	tpl, err := template.New("").Parse(content)
 	if err != nil {
 		return err
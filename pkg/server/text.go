@@ -1,42 +1,137 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/kyleterry/jot/pkg/auth"
 	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/diff"
+	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/kyleterry/jot/pkg/highlight"
+	"github.com/kyleterry/jot/pkg/markdown"
 	"github.com/kyleterry/jot/pkg/text"
 	"github.com/kyleterry/jot/pkg/types"
 )
 
+// liveChunkSize is the most content a live upload appends to a jot at once.
+const liveChunkSize = 32 << 10
+
+// maxDiffWork bounds the work of comparing revisions, which anyone who can
+// read a jot can ask for, to a fraction of a second.
+const maxDiffWork = 1 << 24
+
 // jotHandler handles GET, PUT, DELETE requests for a jot
 type jotHandler struct {
 	store           text.StoreService
 	passwordManager auth.PasswordManagerService
+	broker          *events.Broker
+	sessions        *editSessions
 	cfg             *config.Config
 	getHandler      http.Handler
 	postHandler     http.Handler
 	putHandler      http.Handler
 	deleteHandler   http.Handler
+	revisionHandler http.Handler
+	restoreHandler  http.Handler
+	diffHandler     http.Handler
+	appendHandler   http.Handler
+	eventsHandler   http.Handler
+	liveHandler     http.Handler
+	forkHandler     http.Handler
+	// editHandler serves the editor page, editStreamHandler the changes
+	// made in it and editOpHandler takes the changes of one editor.
+	// editFormHandler serves the editor that saves with PUT instead.
+	editHandler       http.Handler
+	editFormHandler   http.Handler
+	editStreamHandler http.Handler
+	editOpHandler     http.Handler
 }
 
 func (h jotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var handler http.Handler
 
-	switch r.Method {
-	case http.MethodGet:
-		handler = h.getHandler
-	case http.MethodPost:
-		handler = h.postHandler
-	case http.MethodPut:
-		handler = h.putHandler
-	case http.MethodDelete:
-		handler = h.deleteHandler
+	// jots can have sub-resources under /txt/<key>/<resource>
+	_, tail := shiftPath(r.URL.Path)
+	resource, _ := shiftPath(tail)
+
+	switch resource {
+	case "":
+		key, _ := shiftPath(r.URL.Path)
+
+		switch r.Method {
+		case http.MethodGet:
+			handler = h.getHandler
+		case http.MethodPost:
+			handler = h.postHandler
+			if r.URL.Query().Has("live") {
+				handler = h.liveHandler
+			}
+		case http.MethodPut:
+			handler = h.putHandler
+			// curl -T uploads to the collection with PUT
+			if key == "" {
+				handler = h.liveHandler
+			}
+		case http.MethodDelete:
+			handler = h.deleteHandler
+		}
+	case "revisions":
+		switch r.Method {
+		case http.MethodGet:
+			handler = h.revisionHandler
+		case http.MethodPost:
+			handler = h.restoreHandler
+		}
+	case "diff":
+		if r.Method == http.MethodGet {
+			handler = h.diffHandler
+		}
+	case "append":
+		if r.Method == http.MethodPost {
+			handler = h.appendHandler
+		}
+	case "events":
+		if r.Method == http.MethodGet {
+			handler = h.eventsHandler
+		}
+	case "fork":
+		if r.Method == http.MethodPost {
+			handler = h.forkHandler
+		}
+	case "edit":
+		switch r.Method {
+		case http.MethodGet:
+			handler = h.editHandler
+			if accepts(r, "text/event-stream") {
+				handler = h.editStreamHandler
+			} else if r.URL.Query().Has("form") {
+				handler = h.editFormHandler
+			}
+		case http.MethodPost:
+			handler = h.editOpHandler
+		}
 	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if handler == nil {
 		http.Error(w, "not implemented", http.StatusNotImplemented)
 
 		return
@@ -45,46 +140,563 @@ func (h jotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// readOptions are the query parameters that change how a jot is read.
+type readOptions struct {
+	download bool
+	follow   bool
+	markdown bool
+}
+
+type readOptionsCtxKey struct{}
+
+// withReadOptions parses the query parameters of a read before the jot is
+// loaded. Loading a jot that burns after reading deletes it, so a bad
+// parameter must fail the request while the jot is still there.
+func withReadOptions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			opts readOptions
+			err  error
+		)
+
+		if opts.download, err = boolFromQuery(r, "download"); err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		if opts.follow, err = boolFromQuery(r, "follow"); err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		if opts.markdown, err = markdownRequested(r); err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), readOptionsCtxKey{}, opts)))
+	})
+}
+
 func (h jotHandler) get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jotFile := types.TextFileFromContext(ctx)
+	opts, _ := ctx.Value(readOptionsCtxKey{}).(readOptions)
 
 	w.Header().Set("content-type", "text/plain; charset=utf-8")
 	w.Header().Set("etag", jotFile.ModifiedDate.Format(time.RFC3339Nano))
+	setExpiresHeader(w, jotFile.ObjectMeta)
+	setForkOfHeader(w, r, h.cfg, jotFile)
 
 	defer jotFile.Content.Close()
 
-	seeker := jotFile.Content.(io.ReadSeeker)
+	if jotFile.BurnAfterReading {
+		// the content is already gone from the store, so it can't be cached
+		// or fetched again in ranges.
+		w.Header().Set("cache-control", "no-store")
+		r.Header.Del("range")
+	}
+
+	download := opts.download
+
+	if wantsJSON(r) && !download {
+		h.getJSON(w, r, jotFile)
 
-	http.ServeContent(w, r, "", jotFile.ModifiedDate, seeker)
+		return
+	}
+
+	if download || jotFile.Filename != "" {
+		setContentDisposition(w, jotFile, download)
+	}
+
+	if opts.follow && !jotFile.BurnAfterReading {
+		setStoredContentType(w, jotFile)
+		h.follow(w, r, jotFile, nil)
+
+		return
+	}
+
+	// jots that are still being uploaded are streamed until the upload ends.
+	if done := h.broker.Live(jotFile.Key); done != nil && !jotFile.BurnAfterReading {
+		w.Header().Del("etag")
+
+		setStoredContentType(w, jotFile)
+		h.follow(w, r, jotFile, done)
+
+		return
+	}
+
+	// downloads are always the content as it was uploaded.
+	if download {
+		setStoredContentType(w, jotFile)
+		serveContent(w, r, jotFile.ModifiedDate, jotFile.Content)
+
+		return
+	}
+
+	if jotFile.Encrypted {
+		h.encrypted(w, r, jotFile)
+
+		return
+	}
+
+	// jots asked for with an extension or language are rendered for
+	// browsers only, so the response depends on what the client accepts.
+	if languageFromRequest(r) != "" {
+		w.Header().Set("vary", "accept")
+	}
+
+	if opts.markdown {
+		h.markdown(w, r, jotFile)
+
+		return
+	}
+
+	// browsers asking for a language get a highlighted page, everyone else
+	// gets the raw content.
+	if lang := languageFromRequest(r); lang != "" && acceptsHTML(r) {
+		h.highlighted(w, r, jotFile, lang)
+
+		return
+	}
+
+	setStoredContentType(w, jotFile)
+	serveContent(w, r, jotFile.ModifiedDate, jotFile.Content)
+}
+
+// serveContent serves the raw content of a jot. Content stored compressed
+// with a coding the client accepts is sent as it's stored, with an ETag of its
+// own. Ranges are of the decompressed content, so they're always served from
+// it.
+func serveContent(w http.ResponseWriter, r *http.Request, modified time.Time, content io.ReadCloser) {
+	if encoded, ok := content.(types.EncodedContent); ok {
+		w.Header().Add("vary", "accept-encoding")
+
+		for _, encoding := range types.Encodings {
+			if r.Header.Get("range") != "" || !acceptsEncoding(r, encoding) {
+				continue
+			}
+
+			if raw, ok := encoded.Encoded(encoding); ok {
+				if etag := w.Header().Get("etag"); etag != "" {
+					w.Header().Set("etag", types.EncodedETag(etag, encoding))
+				}
+
+				w.Header().Set("content-encoding", encoding)
+				http.ServeContent(w, r, "", modified, raw)
+
+				return
+			}
+		}
+	}
+
+	http.ServeContent(w, r, "", modified, content.(io.ReadSeeker))
+}
+
+// setStoredContentType sends the media type a jot was uploaded with. Since it
+// can be anything, including HTML, the content is sandboxed so it can't run
+// scripts on this site.
+func setStoredContentType(w http.ResponseWriter, jotFile *types.TextFile) {
+	if jotFile.ContentType == "" {
+		return
+	}
+
+	w.Header().Set("content-type", jotFile.ContentType)
+	w.Header().Set("content-security-policy", "sandbox")
+	w.Header().Set("x-content-type-options", "nosniff")
+}
+
+// highlighted renders the content of a jot as lang with line numbers.
+func (h jotHandler) highlighted(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile, lang string) {
+	content, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to read jot").WithCause(err), w)
+
+		return
+	}
+
+	lines, language, err := highlight.Lines(lang, string(content))
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to highlight jot").WithCause(err), w)
+
+		return
+	}
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := highlightPage(jotFile.Key, language, lines).Render(r.Context(), w); err != nil {
+		log.Println(fmt.Errorf("error while rendering highlighted page: %w", err))
+	}
+}
+
+// markdown renders the content of a jot as markdown.
+func (h jotHandler) markdown(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile) {
+	content, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to read jot").WithCause(err), w)
+
+		return
+	}
+
+	body, err := markdown.Render(content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to render markdown").WithCause(err), w)
+
+		return
+	}
+
+	w.Header().Set("content-type", HTMLContentType)
+
+	if err := markdownPage(jotFile.Key, body).Render(r.Context(), w); err != nil {
+		log.Println(fmt.Errorf("error while rendering markdown page: %w", err))
+	}
+}
+
+// markdownRequested reports whether the jot should be rendered as markdown.
+// The render query parameter always asks for it, while a .md extension only
+// does for browsers so other clients can still get the raw content.
+func markdownRequested(r *http.Request) (bool, error) {
+	switch render := r.URL.Query().Get("render"); render {
+	case "":
+	case "markdown":
+		return true, nil
+	default:
+		return false, errors.NewBadRequestError("unsupported render mode: " + render)
+	}
+
+	switch KeyExtensionFromContext(r.Context()) {
+	case "md", "markdown":
+		return acceptsHTML(r), nil
+	}
+
+	return false, nil
+}
+
+// boolFromQuery parses the query parameter name as a boolean. It's false when
+// the parameter isn't set.
+func boolFromQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.NewBadRequestError(fmt.Sprintf("invalid %s value: %s", name, value)).WithCause(err)
+	}
+
+	return b, nil
+}
+
+// languageFromRequest returns the language asked for with the lang query
+// parameter or the extension on the key.
+func languageFromRequest(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+
+	return KeyExtensionFromContext(r.Context())
 }
 
 func (h jotHandler) post(w http.ResponseWriter, r *http.Request) {
-	jotFile, err := h.store.Create(r.Context(), r.Body)
+	if err := limitBody(w, r, textLimit(h.cfg)); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	opts, err := jotOptionsFromRequest(r)
 	if err != nil {
 		WriteError(err, w)
 
 		return
 	}
 
+	jotFile, err := h.store.Create(r.Context(), r.Body, opts)
+	if err != nil {
+		WriteError(limitError(err), w)
+
+		return
+	}
+
 	writeCreatedResponse(w, r, h.cfg, "txt", jotFile.Key, jotFile.Password)
 }
 
+// live creates a jot before its content has arrived and appends the request
+// body to it as it's read. The created response is sent straight away so the
+// URL can be shared while the upload is running, and readers of the jot get
+// the content as it's appended until the upload ends.
+func (h jotHandler) live(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := limitBody(w, r, textLimit(h.cfg)); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	opts, err := jotOptionsFromRequest(r)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	// the first reader would delete the jot while it's being written to.
+	if opts.BurnAfterReading {
+		WriteError(errors.NewBadRequestError("live uploads can't burn after reading"), w)
+
+		return
+	}
+
+	jotFile, err := h.store.Create(ctx, http.NoBody, opts)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	end := h.broker.StartLive(jotFile.Key)
+	defer end()
+
+	// HTTP/1 servers read the whole request body before responding unless
+	// told otherwise. HTTP/2 doesn't support the call because it's always
+	// full duplex, so the error is ignored.
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	writeCreatedResponse(w, r, h.cfg, "txt", jotFile.Key, jotFile.Password)
+	rc.Flush()
+
+	// the jot starts out empty, so the upload counts what it appends instead
+	// of measuring the jot before every chunk. Appends made by others while
+	// it runs check the size themselves.
+	var size int64
+
+	buf := make([]byte, liveChunkSize)
+
+	for {
+		n, err := r.Body.Read(buf)
+		if n > 0 {
+			if h.cfg.MaxTextSize > 0 && size+int64(n) > h.cfg.MaxTextSize {
+				log.Println(fmt.Errorf("error while appending live upload: %w", newJotTooLargeError(h.cfg.MaxTextSize)))
+
+				if err := h.store.Delete(ctx, jotFile); err != nil {
+					log.Println(fmt.Errorf("error while deleting live upload: %w", err))
+				}
+
+				return
+			}
+
+			chunk := io.NopCloser(bytes.NewReader(buf[:n]))
+
+			if err := h.store.Append(ctx, jotFile, chunk); err != nil {
+				log.Println(fmt.Errorf("error while appending live upload: %w", err))
+
+				return
+			}
+
+			size += int64(n)
+		}
+
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			log.Println(fmt.Errorf("error while reading live upload: %w", err))
+
+			// an upload cut off by the size limit isn't kept, the same as
+			// any other upload that's too large.
+			if isLimitError(err) {
+				if err := h.store.Delete(ctx, jotFile); err != nil {
+					log.Println(fmt.Errorf("error while deleting live upload: %w", err))
+				}
+			}
+
+			return
+		}
+	}
+}
+
 func (h jotHandler) put(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jotFile := types.TextFileFromContext(ctx)
 
+	if err := limitBody(w, r, textLimit(h.cfg)); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if base, ok := MergeBaseFromContext(ctx); ok {
+		h.merge(w, r, jotFile, base)
+
+		return
+	}
+
 	jotFile.Content = r.Body
 
 	if err := h.store.Update(ctx, jotFile); err != nil {
+		WriteError(limitError(err), w)
+
+		return
+	}
+
+	if wantsJSON(r) {
+		h.writeUpdated(w, r, jotFile.Key)
+
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s", jotFile.Key), http.StatusSeeOther)
+}
+
+// merge stores an edit made to an older revision of a jot, named by base, by
+// merging it line by line with the changes made since. Edits that overlap
+// those changes aren't stored; they're sent back with conflict markers, and
+// the ETag to send once they're resolved, with 409.
+func (h jotHandler) merge(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile, base string) {
+	ctx := r.Context()
+
+	// the server can't read the content to merge it.
+	if jotFile.Encrypted {
+		WriteError(errors.NewBadRequestError("encrypted jots can't be merged"), w)
+
+		return
+	}
+
+	revisions, err := h.store.Revisions(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	idx := slices.IndexFunc(revisions, func(rev *types.Revision) bool {
+		return rev.ETagMatches(base)
+	})
+	if idx < 0 {
+		WriteError(errors.NewETagMismatchError(), w)
+
+		return
+	}
+
+	current := revisions[len(revisions)-1]
+
+	baseLines, err := h.revisionLines(ctx, jotFile.Key, revisions[idx].Number)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	currentLines, err := h.revisionLines(ctx, jotFile.Key, current.Number)
+	if err != nil {
 		WriteError(err, w)
 
 		return
 	}
 
+	edited, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteError(limitError(err), w)
+
+		return
+	}
+
+	merged, ok, err := diff.Merge(baseLines, currentLines, diff.SplitLines(string(edited)), "current", "yours", maxDiffWork)
+	if err != nil {
+		WriteError(diffError(err), w)
+
+		return
+	}
+
+	content := strings.Join(merged, "")
+
+	if !ok {
+		w.Header().Set("content-type", DefaultContentType)
+		w.Header().Set("etag", current.ETag())
+		w.WriteHeader(http.StatusConflict)
+
+		if _, err := io.WriteString(w, content); err != nil {
+			log.Println(fmt.Errorf("error while writing merge conflicts: %w", err))
+		}
+
+		return
+	}
+
+	jotFile.Content = io.NopCloser(strings.NewReader(content))
+
+	if err := h.store.Update(ctx, jotFile); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if wantsJSON(r) {
+		h.writeUpdated(w, r, jotFile.Key)
+
+		return
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/%s", jotFile.Key), http.StatusSeeOther)
 }
 
+// append adds the request body to the end of a jot and returns the new ETag.
+func (h jotHandler) append(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	// content can't be added to the end of a blob the server can't read.
+	if jotFile.Encrypted {
+		WriteError(errors.NewBadRequestError("encrypted jots can't be appended to"), w)
+
+		return
+	}
+
+	// the limit is on the size of the whole jot, so appends can't grow it
+	// past it a request at a time.
+	size, err := h.size(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	limit, err := appendLimit(h.cfg, size)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if err := limitBody(w, r, limit); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if err := h.store.Append(ctx, jotFile, r.Body); err != nil {
+		WriteError(limitError(err), w)
+
+		return
+	}
+
+	updated, err := h.store.Stat(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	w.Header().Set("etag", updated.ETag())
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h jotHandler) delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jotFile := types.TextFileFromContext(ctx)
@@ -95,37 +707,391 @@ func (h jotHandler) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, jotResponse{Key: jotFile.Key, URL: objectURL(h.cfg, r, "txt", jotFile.Key)})
+
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// getJSON describes a jot and includes its content, for clients that asked
+// for JSON. Content that isn't UTF-8 is sent as base64, like requests send
+// it, since a JSON string would replace the bytes that aren't.
+func (h jotHandler) getJSON(w http.ResponseWriter, r *http.Request, jotFile *types.TextFile) {
+	raw, err := io.ReadAll(jotFile.Content)
+	if err != nil {
+		WriteError(errors.NewUnknownError("failed to read jot").WithCause(err), w)
+
+		return
+	}
+
+	resp := newJotResponse(h.cfg, r, jotFile)
+
+	size, content := int64(len(raw)), string(raw)
+	if !utf8.Valid(raw) {
+		content, resp.ContentEncoding = base64.StdEncoding.EncodeToString(raw), base64Encoding
+	}
+
+	resp.Size, resp.Content = &size, &content
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// size returns the size of the content of a jot.
+func (h jotHandler) size(ctx context.Context, key string) (int64, error) {
+	revisions, err := h.store.Revisions(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(revisions) == 0 {
+		return 0, nil
+	}
+
+	return revisions[len(revisions)-1].Size, nil
+}
+
+// checkAppend fails if appending n bytes would grow a jot past MaxTextSize.
+func (h jotHandler) checkAppend(ctx context.Context, key string, n int64) error {
+	if h.cfg.MaxTextSize <= 0 {
+		return nil
+	}
+
+	size, err := h.size(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if size+n > h.cfg.MaxTextSize {
+		return newJotTooLargeError(h.cfg.MaxTextSize)
+	}
+
+	return nil
+}
+
+// writeUpdated describes a jot after it was written, for clients that asked
+// for JSON.
+func (h jotHandler) writeUpdated(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := r.Context()
+
+	jotFile, err := h.store.Stat(ctx, key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	size, err := h.size(ctx, key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	resp := newJotResponse(h.cfg, r, jotFile)
+	resp.Size = &size
+
+	w.Header().Set("etag", jotFile.ETag())
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// revisions lists the revisions of a jot, or returns the content of one
+// revision when the path is /<key>/revisions/<number>.
+func (h jotHandler) revisions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	number, err := revisionFromPath(r.URL.Path)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if number == 0 {
+		revisions, err := h.store.Revisions(ctx, jotFile.Key)
+		if err != nil {
+			WriteError(err, w)
+
+			return
+		}
+
+		w.Header().Set("content-type", DefaultContentType)
+
+		for _, rev := range revisions {
+			if _, err := fmt.Fprintf(w, "%d %s %d\n", rev.Number, rev.ETag(), rev.Size); err != nil {
+				log.Println(fmt.Errorf("error while writing revisions: %w", err))
+
+				return
+			}
+		}
+
+		return
+	}
+
+	rev, err := h.store.GetRevision(ctx, jotFile.Key, number)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	defer rev.Content.Close()
+
+	w.Header().Set("content-type", DefaultContentType)
+	w.Header().Set("etag", rev.ETag())
+
+	serveContent(w, r, rev.ModifiedDate, rev.Content)
+}
+
+// fork copies a jot into a new jot with its own password, so anyone can change
+// a copy of a jot without knowing the password of the original.
+func (h jotHandler) fork(w http.ResponseWriter, r *http.Request) {
+	jotFile, err := h.store.Fork(r.Context(), types.TextFileFromContext(r.Context()))
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	writeCreatedResponse(w, r, h.cfg, "txt", jotFile.Key, jotFile.Password)
+}
+
+// setForkOfHeader links a forked jot to the jot it was forked from.
+func setForkOfHeader(w http.ResponseWriter, r *http.Request, cfg *config.Config, jotFile *types.TextFile) {
+	if jotFile.ForkOf == "" {
+		return
+	}
+
+	u, err := url.Parse(extractHost(cfg, r))
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("link", fmt.Sprintf(`<%s>; rel="fork-of"`, u.JoinPath("txt", jotFile.ForkOf)))
+}
+
+// restore makes an old revision the current content of a jot.
+func (h jotHandler) restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	number, err := revisionFromPath(r.URL.Path)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	if number == 0 {
+		WriteError(errors.NewBadRequestError("a revision number is required to restore"), w)
+
+		return
+	}
+
+	if err := h.store.Restore(ctx, jotFile, number); err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	http.Redirect(w, r, path.Join("/txt", jotFile.Key), http.StatusSeeOther)
+}
+
+// diff compares two revisions of a jot. Browsers get a side by side view and
+// everyone else gets a unified diff. The from and to query parameters default
+// to the revision before the current one and the current one.
+func (h jotHandler) diff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jotFile := types.TextFileFromContext(ctx)
+
+	revisions, err := h.store.Revisions(ctx, jotFile.Key)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	latest := revisions[len(revisions)-1].Number
+
+	from, err := revisionFromQuery(r, "from", max(latest-1, 1))
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	to, err := revisionFromQuery(r, "to", latest)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	a, err := h.revisionLines(ctx, jotFile.Key, from)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	b, err := h.revisionLines(ctx, jotFile.Key, to)
+	if err != nil {
+		WriteError(err, w)
+
+		return
+	}
+
+	edits, err := diff.Lines(a, b, maxDiffWork)
+	if err != nil {
+		WriteError(diffError(err), w)
+
+		return
+	}
+
+	if acceptsHTML(r) {
+		rows := diff.SideBySide(edits)
+
+		w.Header().Set("content-type", HTMLContentType)
+
+		if err := diffPage(jotFile.Key, from, to, rows).Render(ctx, w); err != nil {
+			log.Println(fmt.Errorf("error while rendering diff page: %w", err))
+		}
+
+		return
+	}
+
+	fromName := fmt.Sprintf("%s/revisions/%d", jotFile.Key, from)
+	toName := fmt.Sprintf("%s/revisions/%d", jotFile.Key, to)
+
+	w.Header().Set("content-type", "text/x-diff; charset=utf-8")
+
+	if _, err := io.WriteString(w, diff.Unified(fromName, toName, edits, 3)); err != nil {
+		log.Println(fmt.Errorf("error while writing diff: %w", err))
+	}
+}
+
+// diffError returns the error to send for a failed comparison of revisions.
+func diffError(err error) *errors.StoreError {
+	if stderrors.Is(err, diff.ErrTooDifferent) {
+		return errors.NewTooLargeError("the revisions are too different to compare")
+	}
+
+	return errors.NewUnknownError("failed to compare revisions").WithCause(err)
+}
+
+// revisionLines reads the content of a revision split into lines.
+func (h jotHandler) revisionLines(ctx context.Context, key string, number int) ([]string, error) {
+	rev, err := h.store.GetRevision(ctx, key, number)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rev.Content.Close()
+
+	content, err := io.ReadAll(rev.Content)
+	if err != nil {
+		return nil, errors.NewUnknownError("failed to read revision").WithCause(err)
+	}
+
+	return diff.SplitLines(string(content)), nil
+}
+
+// revisionFromQuery returns the revision number in the query parameter name, or
+// def when it isn't set.
+func revisionFromQuery(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, errors.NewBadRequestError(fmt.Sprintf("invalid %s revision: %s", name, value))
+	}
+
+	return number, nil
+}
+
+// revisionFromPath returns the revision number from a /<key>/revisions/<number>
+// path, or 0 if the path doesn't name a revision.
+func revisionFromPath(p string) (int, error) {
+	_, tail := shiftPath(p)
+	_, tail = shiftPath(tail)
+	rev, _ := shiftPath(tail)
+
+	if rev == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(rev)
+	if err != nil || number < 1 {
+		return 0, errors.NewBadRequestError("invalid revision: " + rev)
+	}
+
+	return number, nil
+}
+
 // NewJotHandler returns a new jotHandler setting the relevant middleware and creating
 // a simple mux that switched on http method.
-func NewJotHandler(cfg *config.Config, store text.StoreService, pm auth.PasswordManagerService) *jotHandler {
+func NewJotHandler(cfg *config.Config, store text.StoreService, pm auth.PasswordManagerService, broker *events.Broker) *jotHandler {
 	h := &jotHandler{
 		cfg:             cfg,
 		store:           store,
 		passwordManager: pm,
+		broker:          broker,
+		sessions:        newEditSessions(store, broker),
 	}
 
 	authenticated := NewMiddleware(WithAuthenticationMiddleware(h.passwordManager))
-	keyRequired := NewMiddleware(WithKeyRequiredMiddleware)
+	keyRequired := NewMiddleware(WithKeyRequiredMiddleware, WithKeyExtensionMiddleware)
+	jotTagged := withPreloaded(func(ctx context.Context, key string) (*types.TextFile, error) {
+		return h.store.Stat(ctx, key)
+	})
+	jotPreloaded := NewMiddleware(jotTagged, WithPreconditionsMiddleware)
+	// edits to an older revision can be merged instead of failing the
+	// precondition.
+	jotMerged := NewMiddleware(jotTagged, WithMergeMiddleware, WithPreconditionsMiddleware)
+	// readers load the content, which deletes jots that burn after reading.
+	// Writers never need the stored content so they only load the metadata.
 	jotLoaded := NewMiddleware(
-		withPreloaded(func(ctx context.Context, key string) (*types.TextFile, error) {
-			return h.store.Stat(ctx, key)
-		}),
-		WithPreconditionsMiddleware,
 		withLoaded(func(ctx context.Context, key string) (*types.TextFile, error) {
 			return h.store.Get(ctx, key)
 		}, types.WithTextFile),
 	)
+	jotStatted := NewMiddleware(
+		withLoaded(func(ctx context.Context, key string) (*types.TextFile, error) {
+			return h.store.Stat(ctx, key)
+		}, types.WithTextFile),
+	)
 
-	authenticated = keyRequired.ExtendWith(authenticated, jotLoaded)
-	keyRequired = keyRequired.ExtendWith(jotLoaded)
+	// revisions carry their own ETags, so the preconditions of the current
+	// content don't apply to them.
+	revisions := keyRequired.ExtendWith(jotStatted)
+	restore := keyRequired.ExtendWith(authenticated, jotStatted)
+	merged := keyRequired.ExtendWith(authenticated, jotMerged, jotStatted)
+	authenticated = keyRequired.ExtendWith(authenticated, jotPreloaded, jotStatted)
+	keyRequired = keyRequired.ExtendWith(NewMiddleware(withReadOptions), jotPreloaded, jotLoaded)
 
 	h.getHandler = keyRequired.Wrap(http.HandlerFunc((*h).get))
 	h.postHandler = http.HandlerFunc((*h).post)
-	h.putHandler = authenticated.Wrap(http.HandlerFunc((*h).put))
+	h.liveHandler = http.HandlerFunc((*h).live)
+	h.putHandler = merged.Wrap(http.HandlerFunc((*h).put))
 	h.deleteHandler = authenticated.Wrap(http.HandlerFunc((*h).delete))
+	h.revisionHandler = revisions.Wrap(http.HandlerFunc((*h).revisions))
+	h.restoreHandler = restore.Wrap(http.HandlerFunc((*h).restore))
+	h.diffHandler = revisions.Wrap(http.HandlerFunc((*h).diff))
+	h.appendHandler = authenticated.Wrap(http.HandlerFunc((*h).append))
+	h.eventsHandler = revisions.Wrap(http.HandlerFunc((*h).events))
+	h.forkHandler = revisions.Wrap(http.HandlerFunc((*h).fork))
+	// the editor page asks for the password itself, and the edit session
+	// checks it when it's joined.
+	h.editHandler = revisions.Wrap(http.HandlerFunc((*h).edit))
+	h.editFormHandler = revisions.Wrap(http.HandlerFunc((*h).editForm))
+	h.editStreamHandler = restore.Wrap(http.HandlerFunc((*h).editStream))
+	h.editOpHandler = restore.Wrap(http.HandlerFunc((*h).editOp))
 
 	return h
 }
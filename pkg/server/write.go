@@ -13,20 +13,27 @@ import (
 // WriteError takes an error and writes its text to the http response and sets
 // the status code. If the err is an errors.StorageError, then the status code
 // is extracted from the StatusCode field. Otherwise an
-// http.StatusInternalServerError is used.
+// http.StatusInternalServerError is used. Clients that asked for JSON get the
+// error as a problem.
 func WriteError(err error, w http.ResponseWriter) {
-	if storeErr, ok := err.(*errors.StoreError); ok {
+	storeErr, ok := err.(*errors.StoreError)
+	if ok {
 		for _, cause := range storeErr.Causes {
 			log.Printf("[error cause] %s", cause)
 		}
+	} else {
+		log.Printf("[error cause] %s", err)
 
-		http.Error(w, storeErr.Message, storeErr.StatusCode)
+		storeErr = errors.NewUnknownError("internal server error")
+	}
+
+	if _, ok := w.(jsonResponseWriter); ok {
+		writeProblem(w, storeErr)
 
 		return
 	}
 
-	log.Printf("[error cause] %s", err)
-	http.Error(w, "internal server error", http.StatusInternalServerError)
+	http.Error(w, storeErr.Message, storeErr.StatusCode)
 }
 
 // writeCreatedResponse builds the resource URL from the host, routePath, and key,
@@ -43,7 +50,15 @@ func writeCreatedResponse(w http.ResponseWriter, r *http.Request, cfg *config.Co
 
 	u = u.JoinPath(routePath, key)
 
+	w.Header().Set("location", u.String())
 	w.Header().Set("jot-password", password)
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusCreated, createdResponse{Key: key, URL: u.String(), Password: password})
+
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 
 	if _, err := fmt.Fprintf(w, "%s\n", u.String()); err != nil {
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Reapable is implemented by stores that can delete their expired objects.
+type Reapable interface {
+	Reap(ctx context.Context, now time.Time) (int, error)
+}
+
+// Reaper periodically deletes expired objects from a set of stores.
+type Reaper struct {
+	interval time.Duration
+	stores   []Reapable
+}
+
+// Run reaps every store once and then again on every interval until ctx is
+// cancelled. It returns straight away when the interval isn't positive, which
+// turns the reaper off.
+func (r *Reaper) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		log.Println("[reaper] off, expired objects won't be deleted")
+
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Reaper) reap(ctx context.Context) {
+	now := time.Now()
+
+	for _, s := range r.stores {
+		n, err := s.Reap(ctx, now)
+		if err != nil {
+			log.Printf("[reaper] %s", err)
+		}
+
+		if n > 0 {
+			log.Printf("[reaper] deleted %d expired objects", n)
+		}
+	}
+}
+
+// NewReaper returns a Reaper that reaps stores every interval.
+func NewReaper(interval time.Duration, stores ...Reapable) *Reaper {
+	return &Reaper{
+		interval: interval,
+		stores:   stores,
+	}
+}
@@ -0,0 +1,42 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kyleterry/jot/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+type countingReapable struct {
+	reaps int
+}
+
+func (c *countingReapable) Reap(context.Context, time.Time) (int, error) {
+	c.reaps++
+
+	return 0, nil
+}
+
+func TestReaperOff(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		r := &countingReapable{}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			store.NewReaper(interval, r).Run(context.Background())
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("reaper with interval %s didn't return", interval)
+		}
+
+		require.Zero(t, r.reaps)
+	}
+}
@@ -1,10 +1,14 @@
 package store
 
 import (
+	"fmt"
+
 	"github.com/google/wire"
 	"github.com/kyleterry/jot/pkg/auth"
 	"github.com/kyleterry/jot/pkg/errors"
+	"github.com/kyleterry/jot/pkg/events"
 	"github.com/kyleterry/jot/pkg/id"
+	"github.com/kyleterry/jot/pkg/search"
 )
 
 var ProviderSet = wire.NewSet(
@@ -15,6 +19,55 @@ var ProviderSet = wire.NewSet(
 type Options struct {
 	PasswordManager *auth.PasswordManager
 	IDManager       *id.IDManager
+	// Events is told about every write. It can be nil.
+	Events *events.Broker
+	// Search indexes the content of every jot written. It can be nil.
+	Search *search.Index
+}
+
+const (
+	// MinKeyLength and MaxKeyLength bound the length of keys chosen by
+	// clients.
+	MinKeyLength = 3
+	MaxKeyLength = 64
+)
+
+// ValidateKey checks a key chosen by a client for a new object. Keys can only
+// hold ASCII letters, digits, - and _, so they're safe in URLs and file names
+// and never clash with the files backends keep next to objects.
+func ValidateKey(key string) error {
+	if len(key) < MinKeyLength || len(key) > MaxKeyLength {
+		return errors.NewBadRequestError(fmt.Sprintf("key must be between %d and %d characters long: %s", MinKeyLength, MaxKeyLength, key))
+	}
+
+	for _, r := range key {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_':
+		default:
+			return errors.NewBadRequestError("key can only contain letters, digits, - and _: " + key)
+		}
+	}
+
+	return nil
+}
+
+// KeyAndPassword returns the key a new object is created under and its
+// password. An empty key gets a generated one, any other is validated.
+func KeyAndPassword(key string, im *id.IDManager, pm *auth.PasswordManager) (string, string, error) {
+	if key == "" {
+		return NewIDAndPassword(im, pm)
+	}
+
+	if err := ValidateKey(key); err != nil {
+		return "", "", err
+	}
+
+	password, err := pm.Generate(key)
+	if err != nil {
+		return "", "", errors.NewUnknownError("failed to generate password").WithCause(err)
+	}
+
+	return key, password, nil
 }
 
 // NewIDAndPassword generates a unique key and its corresponding password.
@@ -0,0 +1,97 @@
+// Package testserver starts a complete jot server for tests of packages that
+// talk to one over HTTP, the server's own included. It can't live in testutil
+// because it imports the server, whose store tests import testutil.
+package testserver
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/gokey"
+	"github.com/kyleterry/jot/pkg/auth"
+	"github.com/kyleterry/jot/pkg/blob"
+	"github.com/kyleterry/jot/pkg/config"
+	"github.com/kyleterry/jot/pkg/events"
+	"github.com/kyleterry/jot/pkg/id"
+	pkgimage "github.com/kyleterry/jot/pkg/image"
+	imagefs "github.com/kyleterry/jot/pkg/image/backend/filesystem"
+	"github.com/kyleterry/jot/pkg/jot"
+	"github.com/kyleterry/jot/pkg/jot/store/backends"
+	"github.com/kyleterry/jot/pkg/search"
+	"github.com/kyleterry/jot/pkg/server"
+	"github.com/kyleterry/jot/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+// MasterPassword is the master password the seed file of the server is
+// encrypted with.
+const MasterPassword = "test password"
+
+// Server is a jot server started for a test.
+type Server struct {
+	*httptest.Server
+	// Config is the configuration the server runs with. Its DataDir is a
+	// temporary directory that only holds what the server stored.
+	Config *config.Config
+}
+
+// New starts a server that stores jots and images in a temporary directory.
+// configure can change the configuration before the server is built. The
+// server is closed and the directory removed when the test finishes.
+func New(t *testing.T, configure ...func(*config.Config)) *Server {
+	t.Helper()
+
+	seedPath := filepath.Join(t.TempDir(), "seed")
+	seedBytes, err := gokey.GenerateEncryptedKeySeed(MasterPassword)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(seedPath, seedBytes, 0o600))
+
+	cfg := &config.Config{
+		SeedFileLocation: auth.SeedFileLocation(seedPath),
+		MasterPassword:   auth.MasterPassword(MasterPassword),
+		DataDir:          config.DataDir(t.TempDir()),
+	}
+
+	for _, c := range configure {
+		c(cfg)
+	}
+
+	sf, err := auth.NewSeedFile(cfg.MasterPassword, cfg.SeedFileLocation, auth.DefaultSpec())
+	require.NoError(t, err)
+	pm := auth.NewPasswordManager(sf)
+
+	idm, err := id.NewIDManager()
+	require.NoError(t, err)
+
+	broker := events.NewBroker()
+	index := search.New("")
+
+	blobs, err := blob.New(&blob.Options{StorageDir: cfg.DataDir, Compression: cfg.Compression})
+	require.NoError(t, err)
+
+	fs, err := backends.NewFilesystem(backends.ProvideFilesystemOptions(cfg.DataDir), blobs)
+	require.NoError(t, err)
+
+	textStore := jot.NewStore(fs, &store.Options{
+		PasswordManager: pm,
+		IDManager:       idm,
+		Events:          broker,
+		Search:          index,
+	})
+
+	imgBackend, err := imagefs.New(&imagefs.Options{StorageDir: cfg.DataDir, Blobs: blobs})
+	require.NoError(t, err)
+
+	imgStore := pkgimage.NewStore(imgBackend, &store.Options{PasswordManager: pm, IDManager: idm})
+
+	jr := server.NewJotHandler(cfg, textStore, pm, broker)
+	ir := server.NewImageHandler(cfg, imgStore, pm)
+	ar := server.NewAdminHandler(cfg, textStore, index)
+
+	ts := httptest.NewServer(server.New(cfg, jr, ir, ar, server.NewAPIHandler(cfg, jr, ir)))
+	t.Cleanup(ts.Close)
+
+	return &Server{Server: ts, Config: cfg}
+}
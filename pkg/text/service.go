@@ -11,7 +11,12 @@ import (
 type StoreService interface {
 	Stat(ctx context.Context, key string) (*types.TextFile, error)
 	Get(ctx context.Context, key string) (*types.TextFile, error)
-	Create(ctx context.Context, content io.ReadCloser) (*types.TextFile, error)
+	Create(ctx context.Context, content io.ReadCloser, opts types.CreateOptions) (*types.TextFile, error)
 	Update(ctx context.Context, jf *types.TextFile) error
+	Append(ctx context.Context, jf *types.TextFile, content io.ReadCloser) error
 	Delete(ctx context.Context, jf *types.TextFile) error
+	Revisions(ctx context.Context, key string) ([]*types.Revision, error)
+	GetRevision(ctx context.Context, key string, number int) (*types.TextFile, error)
+	Restore(ctx context.Context, jf *types.TextFile, number int) error
+	Fork(ctx context.Context, jf *types.TextFile) (*types.TextFile, error)
 }
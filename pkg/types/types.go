@@ -11,14 +11,39 @@ import (
 // methods shared by all stored objects.
 type ObjectMeta struct {
 	ModifiedDate time.Time
+	// ExpiresAt is the time after which the object is gone. A zero value means
+	// the object never expires.
+	ExpiresAt time.Time
 }
 
 func (m ObjectMeta) ETag() string {
 	return m.ModifiedDate.Format(time.RFC3339Nano)
 }
 
+// Encodings are the content codings compressed content can be sent in, in the
+// order they're preferred.
+var Encodings = []string{"zstd", "gzip"}
+
+// ETagMatches reports whether compare is the ETag of the object, as is or in
+// one of the Encodings.
 func (m ObjectMeta) ETagMatches(compare string) bool {
-	return compare == m.ETag()
+	if compare == m.ETag() {
+		return true
+	}
+
+	for _, encoding := range Encodings {
+		if compare == EncodedETag(m.ETag(), encoding) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EncodedETag returns the ETag of content sent in the content coding encoding,
+// which is a different representation than the content as is.
+func EncodedETag(etag, encoding string) string {
+	return etag + "-" + encoding
 }
 
 func (m ObjectMeta) ShouldLoad(etag string) bool {
@@ -33,10 +58,61 @@ func (m ObjectMeta) HasBeenModified(timestamp string) bool {
 	return timestamp == m.ModifiedDate.Format(http.TimeFormat)
 }
 
+// Expired reports whether the object has an expiry and it has passed at now.
+func (m ObjectMeta) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// CreateOptions holds the optional settings a client can ask for when creating
+// a new object.
+type CreateOptions struct {
+	// Key is the key the client chose for the object. Objects without one
+	// get a generated key.
+	Key       string
+	ExpiresAt time.Time
+	// BurnAfterReading makes a jot readable exactly once.
+	BurnAfterReading bool
+	// ContentType and Filename are served back with a jot. They're empty when
+	// the uploader didn't send them.
+	ContentType string
+	Filename    string
+	// Encrypted marks a jot whose content was encrypted by the client.
+	Encrypted bool
+	// ForkOf is the key of the jot a new jot was forked from.
+	ForkOf string
+}
+
 type TextFile struct {
 	Key      string
 	Content  io.ReadCloser
 	Password string
+	// BurnAfterReading reports whether the jot is deleted by the first read.
+	BurnAfterReading bool
+	// ContentType is the media type the jot was uploaded with, if any.
+	ContentType string
+	// Filename is the name the jot was uploaded with, if any.
+	Filename string
+	// Encrypted reports whether the content was encrypted by the client. The
+	// server can't read it, so it's only ever served back as it was stored.
+	Encrypted bool
+	// ForkOf is the key of the jot this one was forked from, if any.
+	ForkOf string
+	ObjectMeta
+}
+
+// EncodedContent is content that's stored compressed. It can be served to
+// clients that accept the compression without decompressing it.
+type EncodedContent interface {
+	// Encoded returns the content as it's stored if it's compressed with the
+	// HTTP content coding encoding.
+	Encoded(encoding string) (io.ReadSeeker, bool)
+}
+
+// Revision describes one version of a jot's content. Revisions are numbered
+// from 1 and the highest number is the current content.
+type Revision struct {
+	Number int
+	Size   int64
 	ObjectMeta
 }
 
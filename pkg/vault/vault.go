@@ -0,0 +1,151 @@
+// Package vault keeps the passwords of the jots and galleries a user created,
+// so the jot command can change and delete them without asking for the
+// password. Passwords are kept by URL in a JSON file only the user can read.
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// FileName is the name of the vault file in the jot config directory.
+	FileName = "passwords.json"
+
+	filePermissions      = 0o600
+	directoryPermissions = 0o700
+)
+
+// Entry is the password of one jot or gallery.
+type Entry struct {
+	Password string    `json:"password"`
+	Created  time.Time `json:"created"`
+}
+
+// Vault holds passwords by the URL of the jot or gallery they belong to.
+// Changes are written to its file straight away.
+type Vault struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]Entry
+}
+
+// Get returns the password of the jot or gallery at url.
+func (v *Vault) Get(url string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.entries[url]
+
+	return entry.Password, ok
+}
+
+// Put stores the password of the jot or gallery at url.
+func (v *Vault) Put(url, password string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.entries[url] = Entry{Password: password, Created: time.Now().UTC()}
+
+	return v.save()
+}
+
+// Remove forgets the password of the jot or gallery at url.
+func (v *Vault) Remove(url string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.entries[url]; !ok {
+		return nil
+	}
+
+	delete(v.entries, url)
+
+	return v.save()
+}
+
+// save writes the vault to a temporary file and moves it over the old one, so
+// a failed write never loses the passwords already kept.
+func (v *Vault) save() error {
+	raw, err := json.MarshalIndent(v.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), directoryPermissions); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	if err := tmp.Chmod(filePermissions); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	return nil
+}
+
+// Open reads the vault at path. A vault that doesn't exist yet is empty and
+// its file is created when the first password is put in it.
+func Open(path string) (*Vault, error) {
+	v := &Vault{
+		path:    path,
+		entries: make(map[string]Entry),
+	}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return v, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &v.entries); err != nil {
+		return nil, fmt.Errorf("failed to read vault %s: %w", path, err)
+	}
+
+	return v, nil
+}
+
+// DefaultPath returns where the vault is kept: jot/passwords.json in
+// $XDG_CONFIG_HOME, or in ~/.config when that isn't set.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "jot", FileName), nil
+}
@@ -0,0 +1,62 @@
+package vault_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kyleterry/jot/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jot", vault.FileName)
+
+	v, err := vault.Open(path)
+	require.NoError(t, err)
+
+	_, ok := v.Get("http://localhost:8095/txt/abc")
+	require.False(t, ok)
+
+	require.NoError(t, v.Put("http://localhost:8095/txt/abc", "secret"))
+	require.NoError(t, v.Put("http://localhost:8095/img/def", "hunter2"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	v, err = vault.Open(path)
+	require.NoError(t, err)
+
+	password, ok := v.Get("http://localhost:8095/txt/abc")
+	require.True(t, ok)
+	require.Equal(t, "secret", password)
+
+	require.NoError(t, v.Remove("http://localhost:8095/txt/abc"))
+	require.NoError(t, v.Remove("http://localhost:8095/txt/missing"))
+
+	v, err = vault.Open(path)
+	require.NoError(t, err)
+
+	_, ok = v.Get("http://localhost:8095/txt/abc")
+	require.False(t, ok)
+
+	password, ok = v.Get("http://localhost:8095/img/def")
+	require.True(t, ok)
+	require.Equal(t, "hunter2", password)
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+
+	path, err := vault.DefaultPath()
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/config/jot/passwords.json", path)
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/someone")
+
+	path, err = vault.DefaultPath()
+	require.NoError(t, err)
+	require.Equal(t, "/home/someone/.config/jot/passwords.json", path)
+}